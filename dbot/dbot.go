@@ -5,6 +5,8 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"path/filepath"
+	"strconv"
 	"strings"
 
 	"github.com/andersfylling/disgord"
@@ -33,10 +35,56 @@ func parseOp(message string, mcs []defs.MessageCommand) (*defs.ServerRequestOp,
 	return nil, fmt.Errorf("command \"%s\" is not recognized. get it together", message)
 }
 
+// maxMessageLength is the most characters discord allows in one message
+const maxMessageLength = 2000
+
+// splitMessage breaks a message into pieces discord will accept, splitting on line breaks where it can
+func splitMessage(message string) []string {
+	pieces := make([]string, 0)
+	for len(message) > maxMessageLength {
+		cut := strings.LastIndex(message[:maxMessageLength], "\n")
+		if cut <= 0 {
+			cut = maxMessageLength
+		}
+		pieces = append(pieces, message[:cut])
+		message = strings.TrimPrefix(message[cut:], "\n")
+	}
+	return append(pieces, message)
+}
+
+// sendWithFiles sends a message with files uploaded alongside it, one file per message. discord limits the size of a
+// whole message rather than each file, and every file may already be as big as that limit allows
+func sendWithFiles(client *disgord.Client, channelID disgord.Snowflake, content string, files []string) {
+	if len(files) == 0 {
+		_, err := client.CreateMessage(context.Background(), channelID, &disgord.CreateMessageParams{Content: content})
+		if err != nil {
+			fmt.Println("could not send message", err)
+		}
+		return
+	}
+
+	for _, path := range files {
+		file, err := os.Open(path)
+		if err != nil {
+			fmt.Println("could not open upload", path, err)
+			continue
+		}
+		_, err = client.CreateMessage(context.Background(), channelID, &disgord.CreateMessageParams{
+			Content: content,
+			Files:   []disgord.CreateMessageFileParams{{Reader: file, FileName: filepath.Base(path)}},
+		})
+		if err != nil {
+			fmt.Println("could not send message", err)
+		}
+		file.Close()
+		content = ""
+	}
+}
+
 // MakeBotManager starts discord bot that listens to incoming messages, and sends ServerRequestOps when a valid
 // command is requested. it also sends messages back to the discord server based on the messages provided by the
 // discordResponses channel
-func MakeBotManager(serverRequests chan<- *defs.ServerRequestOp, discordResponses chan string) {
+func MakeBotManager(serverRequests chan<- *defs.ServerRequestOp, discordResponses chan *defs.DiscordResponse) {
 	bg := context.Background()
 	client := disgord.New(disgord.Config{
 		BotToken: os.Getenv("BOT_TOKEN"),
@@ -57,9 +105,18 @@ func MakeBotManager(serverRequests chan<- *defs.ServerRequestOp, discordResponse
 			cmd := msg.Content[4:]
 			op, err := parseOp(cmd, defs.Commands)
 			if err != nil {
-				discordResponses <- "ERROR: " + err.Error()
+				discordResponses <- &defs.DiscordResponse{Content: "ERROR: " + err.Error()}
 				return
 			}
+			if len(msg.Attachments) > 0 {
+				// files can't be given as flags, so they ride along as args of their own
+				attachment := msg.Attachments[0]
+				op.Args["_attachment"] = attachment.URL
+				op.Args["_attachmentName"] = attachment.Filename
+				op.Args["_attachmentSize"] = strconv.FormatUint(uint64(attachment.Size), 10)
+			}
+			op.Args["_channel"] = msg.ChannelID.String()
+			op.Args["_guild"] = msg.GuildID.String()
 			serverRequests <- op
 		}
 
@@ -72,9 +129,30 @@ func MakeBotManager(serverRequests chan<- *defs.ServerRequestOp, discordResponse
 	go func() {
 		for {
 			discordMsg := <-discordResponses
-			client.CreateMessage(bg, channelID, &disgord.CreateMessageParams{
-				Content: discordMsg,
-			})
+			destination := channelID
+			if discordMsg.ChannelID != "" {
+				destination = disgord.ParseSnowflakeString(discordMsg.ChannelID)
+			}
+			pieces := splitMessage(discordMsg.Content)
+			for _, piece := range pieces[:len(pieces)-1] {
+				client.CreateMessage(bg, destination, &disgord.CreateMessageParams{
+					Content: piece,
+				})
+			}
+			if len(discordMsg.Files) == 0 {
+				sendWithFiles(client, destination, pieces[len(pieces)-1], nil)
+				if discordMsg.Cleanup != nil {
+					discordMsg.Cleanup()
+				}
+				continue
+			}
+			// uploads can take minutes, and the server manager waits on this channel, so they go out on their own
+			go func(msg *defs.DiscordResponse, content string) {
+				sendWithFiles(client, destination, content, msg.Files)
+				if msg.Cleanup != nil {
+					msg.Cleanup()
+				}
+			}(discordMsg, pieces[len(pieces)-1])
 		}
 	}()
 }
@@ -1,6 +1,7 @@
 package defs
 
-// MessageCommand is configuration data required to parse a discord message into a server operation
+// MessageCommand is configuration data required to parse a discord message into a server operation. a flag arg
+// ending in ":" accepts any flag with that prefix, i.e. "prop:" accepts -prop:pvp=false
 type MessageCommand struct {
 	Command         string
 	FlagArgs        []string
@@ -9,33 +10,52 @@ type MessageCommand struct {
 	HelpText        string
 }
 
+// DiscordResponse is a message to send back to discord, along with any files to upload with it. Cleanup, if set, is
+// called once the files have been uploaded. the message goes to ChannelID if it's set, or the bot's channel if not
+type DiscordResponse struct {
+	Content   string
+	Files     []string
+	Cleanup   func()
+	ChannelID string
+}
+
 // Commands is a list of all available Commands
 var Commands = []MessageCommand{
 	{
 		Command:         "start",
 		AllowUnnamedArg: true,
 		RequestCode:     Start,
-		HelpText:        "start _world-name_ : start the server on the specified world. it won't immediately be available - the bot will message you when it's ready",
+		HelpText:        "start _world-name_ : start a server on the specified world. several worlds can run at once, each on its own port. it won't immediately be available - the bot will message you when it's ready",
 	},
 	{
-		Command:     "stop",
-		RequestCode: Stop,
-		HelpText:    "stop : safely stop a running server",
+		Command:         "stop",
+		AllowUnnamedArg: true,
+		RequestCode:     Stop,
+		HelpText:        "stop _world-name_ : safely stop a running server. the world can be left out if only one is running",
 	},
 	{
-		Command:     "kill",
-		RequestCode: Kill,
-		HelpText:    "kill : unsafely stop a running or starting server (be careful, this could corrupt the minecraft world)",
+		Command:         "save",
+		AllowUnnamedArg: true,
+		RequestCode:     Save,
+		HelpText:        "save _world-name_ : save the world of a running server to disk. the world can be left out if only one is running",
 	},
 	{
-		Command:     "status",
-		RequestCode: Status,
-		HelpText:    "status : report on the status of the server",
+		Command:         "kill",
+		AllowUnnamedArg: true,
+		RequestCode:     Kill,
+		HelpText:        "kill _world-name_ : unsafely stop a running or starting server (be careful, this could corrupt the minecraft world). the world can be left out if only one is running",
 	},
 	{
-		Command:     "address",
-		RequestCode: Address,
-		HelpText:    "address : get the public dns address of the server (what you'll use to connect to it)",
+		Command:         "status",
+		AllowUnnamedArg: true,
+		RequestCode:     Status,
+		HelpText:        "status _world-name_ : report on the status of a server, or of all servers if no world is given",
+	},
+	{
+		Command:         "address",
+		AllowUnnamedArg: true,
+		RequestCode:     Address,
+		HelpText:        "address _world-name_ : get the public dns address of a server (what you'll use to connect to it). the world can be left out if only one is running",
 	},
 	{
 		Command:     "help",
@@ -43,21 +63,134 @@ var Commands = []MessageCommand{
 		HelpText:    "help : list available Commands",
 	},
 	{
-		Command:     "logs",
-		RequestCode: Logs,
-		FlagArgs:    []string{"l", "o"},
-		HelpText:    "logs : print out a list of the most recent logs. control with flags _l_ (limit) and _o_ (offset). i.e. \"!bb logs -l=10 -o=15\"",
+		Command:         "logs",
+		AllowUnnamedArg: true,
+		RequestCode:     Logs,
+		FlagArgs:        []string{"l", "o", "session", "world", "grep", "level", "since"},
+		HelpText:        "logs _world-name_ : print out a list of the most recent logs of a world. control with flags _l_ (limit) and _o_ (offset), and pick an earlier run with _session_ (a number from the \"sessions\" command, or an id). search the logs of one world (or every world, if none is given) with _grep_ (a regex), _level_ (that level or worse) and _since_ (i.e. 2h or 3d). i.e. \"!bb logs my-world -session=2 -l=10 -o=15\" or \"!bb logs -grep=Exception -level=WARN -since=2h\"",
+	},
+	{
+		Command:         "tail",
+		AllowUnnamedArg: true,
+		RequestCode:     Tail,
+		FlagArgs:        []string{"world"},
+		HelpText:        "tail _world-name_ : stream a running server's console into discord every few seconds, until the server stops or you say \"!bb tail stop\"",
+	},
+	{
+		Command:     "notify",
+		RequestCode: Notify,
+		FlagArgs:    []string{"enabled", "quiet"},
+		HelpText:    "notify : show or change whether players joining and leaving are announced in this channel. optional params: _enabled_ and _quiet_ (hours to keep it down, or off). i.e. \"!bb notify -enabled=true -quiet=23-7\"",
+	},
+	{
+		Command:         "sessions",
+		AllowUnnamedArg: true,
+		RequestCode:     Sessions,
+		FlagArgs:        []string{"l"},
+		HelpText:        "sessions _world-name_ : list the recent runs of a world's server (or of every world), with how long they ran and how they ended. control with flag _l_ (limit)",
 	},
 	{
 		Command:     "create",
 		RequestCode: Create,
-		FlagArgs:    []string{"name", "mode"},
-		HelpText:    "create : create a new world. required params: _name_ and _mode_. i.e. \"!bb create -name=my-new-world -mode=creative\"",
+		FlagArgs:    []string{"name", "mode", "version", "seed", "difficulty", "type", "hardcore", "maxplayers", "prop:"},
+		HelpText:    "create : create a new world. required params: _name_ and _mode_ (survival, creative, adventure or spectator). optional params: _version_ (a server jar from the \"versions\" command to pin the world to. without it, the world is pinned to a copy of the current server.jar), _seed_, _difficulty_, _type_ (normal, flat, large_biomes or amplified), _hardcore_, _maxplayers_, and _prop:key_ for any other server.properties key. i.e. \"!bb create -name=my-new-world -mode=survival -seed=1234 -type=amplified -prop:pvp=false\"",
+	},
+	{
+		Command:     "import",
+		RequestCode: Import,
+		FlagArgs:    []string{"name", "mode", "version"},
+		HelpText:    "import : make a new world from a .zip of a world save attached to the message. required params: _name_. optional params: _mode_ (survival, creative, adventure or spectator. survival unless you say otherwise) and _version_. i.e. \"!bb import -name=drews-world\"",
+	},
+	{
+		Command:         "export",
+		RequestCode:     Export,
+		FlagArgs:        []string{"parts"},
+		AllowUnnamedArg: true,
+		HelpText:        "export _world-name_ : post a world as a .zip you can download. safe to do while it's running. if it's too big to upload, you get the overworld alone, or numbered parts with _parts_ (join them back up in order, i.e. \"cat my-world.zip.* > my-world.zip\"). i.e. \"!bb export my-world -parts=true\"",
+	},
+	{
+		Command:         "info",
+		RequestCode:     Info,
+		AllowUnnamedArg: true,
+		HelpText:        "info _world-name_ : show a world's seed, version, in-game time, spawn point, difficulty and when it was last played",
 	},
 	{
 		Command:     "list",
 		RequestCode: List,
-		HelpText:    "list : list the existing worlds",
+		FlagArgs:    []string{"sort"},
+		HelpText:    "list : list the existing worlds, with how much disk they take up and when they were last played. optional params: _sort_ (name, size or recent). i.e. \"!bb list -sort=size\"",
+	},
+	{
+		Command:         "autorestart",
+		RequestCode:     AutoRestartPolicy,
+		FlagArgs:        []string{"enabled", "retries", "backoff", "limit", "window"},
+		AllowUnnamedArg: true,
+		HelpText:        "autorestart _world-name_ : show or change whether a world is restarted automatically after a crash. optional params: _enabled_, _retries_, _backoff_, _limit_ and _window_ (give up after _limit_ crashes within _window_). i.e. \"!bb autorestart my-world -enabled=true -retries=3 -backoff=10s\"",
+	},
+	{
+		Command:         "config",
+		RequestCode:     Config,
+		FlagArgs:        []string{"set"},
+		AllowUnnamedArg: true,
+		HelpText:        "config _world-name_ : show or change the settings a world's server is launched with (jar, java, xms, xmx, flags, args, preset). java can only be one of the binaries the operator allows, and flags and args only take well known options. i.e. \"!bb config my-world -set=xmx=4G\"",
+	},
+	{
+		Command:         "props",
+		RequestCode:     Props,
+		FlagArgs:        []string{"set"},
+		AllowUnnamedArg: true,
+		HelpText:        "props _world-name_ : list a world's server.properties, or change one with _set_. changes to a running world wait for its next start. i.e. \"!bb props my-world -set=difficulty=hard\"",
+	},
+	{
+		Command:         "backup",
+		RequestCode:     Backup,
+		AllowUnnamedArg: true,
+		HelpText:        "backup _world-name_ : save a timestamped archive of a world. safe to do while it's running. the bot will message you when it's done",
+	},
+	{
+		Command:         "backups",
+		RequestCode:     Backups,
+		AllowUnnamedArg: true,
+		HelpText:        "backups _world-name_ : list the backups of a world, with their sizes and ages. running worlds are also backed up every hour and after they stop",
+	},
+	{
+		Command:         "restore",
+		RequestCode:     Restore,
+		FlagArgs:        []string{"backup", "into"},
+		AllowUnnamedArg: true,
+		HelpText:        "restore _world-name_ : replace a stopped world with one of its backups. required params: _backup_ (an id from the \"backups\" command). optional params: _into_ (restore as a new world instead). i.e. \"!bb restore my-world -backup=20240101-120000 -into=my-world-old\"",
+	},
+	{
+		Command:         "archive",
+		RequestCode:     Archive,
+		AllowUnnamedArg: true,
+		HelpText:        "archive _world-name_ : compress a stopped world into the archive store and take it off the list",
+	},
+	{
+		Command:         "delete",
+		RequestCode:     Delete,
+		FlagArgs:        []string{"confirm"},
+		AllowUnnamedArg: true,
+		HelpText:        "delete _world-name_ : delete a stopped world for good. the bot will give you a code to confirm with _confirm_ within a minute. i.e. \"!bb delete my-world -confirm=1a2b\"",
+	},
+	{
+		Command:         "clone",
+		RequestCode:     Clone,
+		FlagArgs:        []string{"name"},
+		AllowUnnamedArg: true,
+		HelpText:        "clone _world-name_ : copy a world, settings and all, under a new name. safe to do while it's running. required params: _name_. i.e. \"!bb clone my-world -name=my-world-test\"",
+	},
+	{
+		Command:         "rename",
+		RequestCode:     Rename,
+		FlagArgs:        []string{"name"},
+		AllowUnnamedArg: true,
+		HelpText:        "rename _world-name_ : give a stopped world (and its backups) a new name. required params: _name_. i.e. \"!bb rename my-world -name=my-old-world\"",
+	},
+	{
+		Command:     "versions",
+		RequestCode: Versions,
+		HelpText:    "versions : list the server jars worlds can be pinned to, and which worlds use them",
 	},
 	{
 		Command:     "drew",
@@ -1,5 +1,7 @@
 package defs
 
+import "time"
+
 // ServerRequestOpCode is an int describing the operation type in a server request
 type ServerRequestOpCode int
 
@@ -24,6 +26,42 @@ const (
 	List
 	// Drew describes a request to tell drew to shut up
 	Drew
+	// Save describes a request to save the world of a running server
+	Save
+	// AutoRestartPolicy describes a request to view or change a world's auto-restart policy
+	AutoRestartPolicy
+	// Config describes a request to view or change a world's launch settings
+	Config
+	// Versions describes a request to list the available server jars
+	Versions
+	// Props describes a request to view or change a world's server.properties
+	Props
+	// Backup describes a request to back up a world
+	Backup
+	// Backups describes a request to list the backups of a world
+	Backups
+	// Restore describes a request to restore a world from one of its backups
+	Restore
+	// Archive describes a request to compress a world into the archive store and remove it
+	Archive
+	// Delete describes a request to delete a world for good
+	Delete
+	// Clone describes a request to copy a world under a new name
+	Clone
+	// Rename describes a request to give a world a new name
+	Rename
+	// Import describes a request to make a new world from a zip attached to the message
+	Import
+	// Export describes a request to post a world to discord as a downloadable archive
+	Export
+	// Info describes a request for the details of a world kept in its level.dat
+	Info
+	// Sessions describes a request to list the recent runs of the servers
+	Sessions
+	// Tail describes a request to stream a server's console into discord, or to stop
+	Tail
+	// Notify describes a request to show or change a discord server's join and leave notifications
+	Notify
 )
 
 // ServerRequestOp is a unit describing an operation in a server request
@@ -44,6 +82,42 @@ const (
 	CreateWorldSuccess
 	// CreateWorldFailure describes a response to the unsuccessful creation of a world
 	CreateWorldFailure
+	// StopTimedOut describes a response to a stopping server failing to exit in time
+	StopTimedOut
+	// AutoRestart describes a response to a crashed server's restart backoff elapsing
+	AutoRestart
+	// BackupSuccess describes a response to the successful backup of a world
+	BackupSuccess
+	// BackupFailure describes a response to the unsuccessful backup of a world
+	BackupFailure
+	// BackupCheck describes a response to the backup scheduler asking whether any world is due a backup
+	BackupCheck
+	// RestoreSuccess describes a response to the successful restore of a world from a backup
+	RestoreSuccess
+	// RestoreFailure describes a response to the unsuccessful restore of a world from a backup
+	RestoreFailure
+	// ArchiveSuccess describes a response to the successful archive of a world
+	ArchiveSuccess
+	// ArchiveFailure describes a response to the unsuccessful archive of a world
+	ArchiveFailure
+	// CloneSuccess describes a response to the successful clone of a world
+	CloneSuccess
+	// CloneFailure describes a response to the unsuccessful clone of a world
+	CloneFailure
+	// RenameSuccess describes a response to the successful rename of a world
+	RenameSuccess
+	// RenameFailure describes a response to the unsuccessful rename of a world
+	RenameFailure
+	// ImportSuccess describes a response to the successful import of a world
+	ImportSuccess
+	// ImportFailure describes a response to the unsuccessful import of a world
+	ImportFailure
+	// ExportSuccess describes a response to a world being packaged for upload
+	ExportSuccess
+	// ExportFailure describes a response to a world that could not be packaged for upload
+	ExportFailure
+	// TailEnded describes a response to the end of the session a tail was streaming
+	TailEnded
 )
 
 // ServerResponseOp is a unit describing an update in a server response
@@ -51,3 +125,36 @@ type ServerResponseOp struct {
 	Args map[string]string
 	Code ServerResponseOpCode
 }
+
+// GameEventCode is an int describing something that happened in a running game
+type GameEventCode int
+
+const (
+	// PlayerJoined describes a player joining the game. Args holds "uuid", "ip" and "online" (how many players are on)
+	PlayerJoined GameEventCode = iota
+	// PlayerLeft describes a player leaving the game. Args holds "reason" and "online"
+	PlayerLeft
+	// Chat describes a chat message. Args holds "message"
+	Chat
+	// Death describes a player dying. Args holds "message", the whole death message
+	Death
+	// Advancement describes a player making an advancement. Args holds "advancement" and "kind" (advancement,
+	// challenge or goal)
+	Advancement
+	// LagWarning describes the server falling behind. Args holds "behind" (in ms) and "ticks"
+	LagWarning
+	// WorldSaved describes the server saving the world
+	WorldSaved
+	// StartupProgress describes the server getting further along in starting. Args holds "stage" (starting,
+	// preparing or done), plus "version", "percent" or "startup" depending on the stage
+	StartupProgress
+)
+
+// GameEvent is a unit describing something that happened in a running game, read from its server's console
+type GameEvent struct {
+	Args   map[string]string
+	Code   GameEventCode
+	World  string
+	Player string
+	Time   time.Time
+}
@@ -8,7 +8,7 @@ import (
 
 func main() {
 	serverRequests := make(chan *defs.ServerRequestOp)
-	discordResponses := make(chan string)
+	discordResponses := make(chan *defs.DiscordResponse)
 
 	mcserver.MakeServerManager(serverRequests, discordResponses)
 	dbot.MakeBotManager(serverRequests, discordResponses)
@@ -3,8 +3,14 @@ package mcserver
 import (
 	"fmt"
 	"io/ioutil"
+	"net"
 	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/matthewdavidrodgers/dbot-mk2/defs"
 	"github.com/matthewdavidrodgers/dbot-mk2/utils"
@@ -12,75 +18,319 @@ import (
 
 type serverAction func(m *manager, args map[string]string) string
 
-var startServerRequestAction = func(m *manager, args map[string]string) string {
-	if m.state == running || m.state == starting {
-		return "ERROR: server is already running; you cannot start it"
-	} else if m.state == stopping {
-		return "ERROR: server is shutting down; wait for it to stop before restarting it"
+func worldExists(name string) bool {
+	worlds, _ := getWorlds()
+	for _, world := range worlds {
+		if world.name == name {
+			return true
+		}
 	}
+	return false
+}
+
+// resolveServer finds the server a command is aimed at: the one for the world given as the unnamed option, or the
+// only active server if no world was given. the server is nil if the world hasn't been run since the bot started,
+// and the string is an error message if no world could be picked
+func resolveServer(m *manager, args map[string]string, command string) (*server, string) {
+	if world, ok := args["_unnamed"]; ok {
+		if !worldExists(world) {
+			return nil, "ERROR: requested world is not valid. please supply an existing world"
+		}
+		return m.servers[world], ""
+	}
+
+	var found *server
+	activeCount := 0
+	for _, s := range m.servers {
+		if s.active() {
+			found = s
+			activeCount++
+		}
+	}
+	if activeCount == 0 {
+		return nil, "ERROR: no servers are running. say which world you mean. i.e. \"!bb " + command + " _my-world_\""
+	}
+	if activeCount > 1 {
+		return nil, "ERROR: more than one server is running. say which world you mean. i.e. \"!bb " + command + " _my-world_\""
+	}
+	return found, ""
+}
 
+var startServerRequestAction = func(m *manager, args map[string]string) string {
 	requestedWorld, ok := args["_unnamed"]
 	if !ok {
 		return "ERROR: world name is missing. please supply as an unnamed option after the command. i.e. \"!bb start _my-world_\""
 	}
-	worldIsValid := false
-	worlds, _ := getWorlds()
-	for _, world := range worlds {
-		if world.name == requestedWorld {
-			worldIsValid = true
-			break
-		}
-	}
-	if !worldIsValid {
+	if !worldExists(requestedWorld) {
 		return "ERROR: requested world is not valid. please supply an existing world or create a new one"
 	}
+	if doing, busy := m.busy[requestedWorld]; busy {
+		return "ERROR: _" + requestedWorld + "_ is " + doing + ". wait for that to finish before starting it"
+	}
 
-	m.state = starting
-	m.server = startServer(m.serverResponses, requestedWorld)
-	return "SERVER IS STARTING. WAIT FOR START MESSAGE TO JOIN."
+	if s, ok := m.servers[requestedWorld]; ok {
+		if s.state == running || s.state == starting {
+			return "ERROR: server for _" + requestedWorld + "_ is already running; you cannot start it"
+		} else if s.state == stopping || s.state == stoppingForcefully {
+			return "ERROR: server for _" + requestedWorld + "_ is shutting down; wait for it to stop before restarting it"
+		}
+	}
+
+	port, ok := freePort(m)
+	if !ok {
+		return fmt.Sprintf("ERROR: all %d server ports are taken. stop another world first", len(m.ports))
+	}
+	s, err := startServer(m.serverResponses, m.events, requestedWorld, port)
+	if err != nil {
+		fmt.Println(err)
+		return "ERROR: could not start the server for _" + requestedWorld + "_"
+	}
+	m.servers[requestedWorld] = s
+	delete(m.restartAttempts, requestedWorld)
+	return fmt.Sprintf("SERVER FOR _%s_ IS STARTING ON PORT %d. WAIT FOR START MESSAGE TO JOIN.", requestedWorld, port)
 }
 
 var stopServerRequestAction = func(m *manager, args map[string]string) string {
-	if m.state != running || m.server == nil {
+	s, errMsg := resolveServer(m, args, "stop")
+	if errMsg != "" {
+		return errMsg
+	}
+	if s == nil || s.state != running {
 		return "ERROR: server is not running; it cannot be stopped"
 	}
-	m.state = stopping
-	m.server.stop()
-	return "STOPPING SERVER"
+	err := s.stop()
+	if err != nil {
+		fmt.Println(err)
+		return "ERROR: could not reach the server console to stop it. try again, or kill it if you must"
+	}
+	s.state = stopping
+	go watchStopDeadline(m.serverResponses, s, m.stopTimeout)
+	return "STOPPING SERVER FOR _" + s.worldName + "_"
+}
+
+var saveServerRequestAction = func(m *manager, args map[string]string) string {
+	s, errMsg := resolveServer(m, args, "save")
+	if errMsg != "" {
+		return errMsg
+	}
+	if s == nil || s.state != running {
+		return "ERROR: server is not running; it cannot be saved"
+	}
+	reply, err := s.console("save-all")
+	if err != nil {
+		fmt.Println(err)
+		return "ERROR: could not reach the server console to save it"
+	}
+	return "SAVING WORLD _" + s.worldName + "_. SERVER SAYS: " + reply
 }
 
 var killServerRequestAction = func(m *manager, args map[string]string) string {
-	if m.server == nil {
+	s, errMsg := resolveServer(m, args, "kill")
+	if errMsg != "" {
+		return errMsg
+	}
+	if s == nil || !s.active() {
 		return "ERROR: no server to kill"
 	}
-	m.state = stopping
-	m.server.kill()
-	m.server = nil
-	return "KILLING SERVER"
+	// not a clean stop, so it shouldn't be treated like one (no backup of a world that may be half saved)
+	s.state = stoppingForcefully
+	s.kill()
+	return "KILLING SERVER FOR _" + s.worldName + "_"
 }
 
-var statusServerRequestAction = func(m *manager, args map[string]string) string {
+func describeServer(s *server) string {
 	var msg string
-	if m.state == starting {
+	if s.state == starting {
 		msg = "SERVER IS STARTING UP. BE PATIENT. I WILL NOTIFY WHEN ITS READY."
-	} else if m.state == idle {
+	} else if s.state == idle {
 		msg = "SERVER IS NOT RUNNING. TELL ME TO START IT. COME ON. I WANT YOU TO DO IT."
-	} else if m.state == crashed {
+	} else if s.state == crashed {
 		msg = "SHIT. SERVER HAS CRASHED. I HAVE NO ANSWERS. ONLY PAIN."
-	} else if m.state == stopping {
+	} else if s.state == stopping {
 		msg = "SERVER IS SHUTTING DOWN. IT IS A FAR BETTER REST THAT I GO TO THAN I HAVE EVER KNOWN."
+	} else if s.state == stoppingForcefully {
+		msg = "SERVER WOULDN'T STOP ON ITS OWN, SO I'M KILLING IT. NOT MY FINEST MOMENT."
+	} else if s.state == stoppedForcefully {
+		msg = "SERVER IS NOT RUNNING. LAST TIME I HAD TO KILL IT, SO MAYBE CHECK THE WORLD."
 	} else {
-		// m.state == running
-		msg = "SERVER IS RUNNING ON WORLD _" + m.server.worldName + "_. BLOC AWAY, MY BOIS.\n" + "server started on " + m.server.startedOn.String()
+		// s.state == running
+		msg = fmt.Sprintf("SERVER IS RUNNING ON WORLD _%s_ (PORT %d). BLOC AWAY, MY BOIS.\nserver started on %s", s.worldName, s.port, s.startedOn.String())
+
+		status, err := pingServer(net.JoinHostPort("localhost", strconv.Itoa(s.port)), 3*time.Second)
+		if err != nil {
+			fmt.Println(err)
+			msg += "\n(the server isn't answering pings right now. weird.)"
+		} else {
+			msg += fmt.Sprintf("\nplayers: %d/%d", status.online, status.max)
+			if len(status.playerNames) > 0 {
+				msg += " (" + strings.Join(status.playerNames, ", ") + ")"
+			}
+			msg += "\nmotd: " + status.motd
+			msg += "\nversion: " + status.version
+		}
+	}
+	return msg
+}
+
+var stateNames = map[serverStateCode]string{
+	idle:               "stopped",
+	starting:           "starting",
+	running:            "running",
+	stopping:           "stopping",
+	crashed:            "crashed",
+	stoppingForcefully: "being killed",
+	stoppedForcefully:  "stopped forcefully",
+}
+
+var statusServerRequestAction = func(m *manager, args map[string]string) string {
+	if world, ok := args["_unnamed"]; ok {
+		if !worldExists(world) {
+			return "ERROR: requested world is not valid. please supply an existing world"
+		}
+		s, ok := m.servers[world]
+		if !ok {
+			return "SERVER FOR _" + world + "_ IS NOT RUNNING. TELL ME TO START IT. COME ON. I WANT YOU TO DO IT."
+		}
+		return describeServer(s)
+	}
+
+	if len(m.servers) == 0 {
+		return "NO SERVERS ARE RUNNING. TELL ME TO START ONE. COME ON. I WANT YOU TO DO IT."
+	}
+	names := make([]string, 0, len(m.servers))
+	for name := range m.servers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	msg := "SERVERS:\n"
+	for _, name := range names {
+		s := m.servers[name]
+		if s.active() {
+			msg += fmt.Sprintf("\n%s: %s (port %d)", name, stateNames[s.state], s.port)
+		} else {
+			msg += fmt.Sprintf("\n%s: %s", name, stateNames[s.state])
+		}
 	}
+	msg += "\n\nFor more on one world, add its name. i.e. \"!bb status _my-world_\""
 	return msg
 }
 
+// searchLogsAction answers a logs command with a search filter, looking through the sessions of one world or every
+// world
+func searchLogsAction(m *manager, args map[string]string) string {
+	filter := logFilter{}
+	if pattern, ok := args["grep"]; ok {
+		compiled, err := regexp.Compile(pattern)
+		if err != nil {
+			return "ERROR: grep is not a valid regex: " + err.Error()
+		}
+		filter.pattern = compiled
+	}
+	if level, ok := args["level"]; ok {
+		filter.minLevel = levelIndex(level)
+		if filter.minLevel < 0 {
+			return "ERROR: level is not valid. options are " + strings.Join(logLevels, ", ")
+		}
+	}
+	if since, ok := args["since"]; ok {
+		duration, err := parseSince(since)
+		if err != nil {
+			return "ERROR: " + err.Error()
+		}
+		filter.since = time.Now().Add(-duration)
+	}
+
+	worlds := make([]string, 0)
+	if world, ok := args["_unnamed"]; ok {
+		if !worldExists(world) {
+			return "ERROR: requested world is not valid. please supply an existing world"
+		}
+		worlds = append(worlds, world)
+	} else {
+		all, err := getWorlds()
+		if err != nil {
+			fmt.Println(err)
+			return "ERROR: could not list the worlds"
+		}
+		for _, world := range all {
+			worlds = append(worlds, world.name)
+		}
+	}
+
+	matches := make([]logMatch, 0)
+	total := 0
+	for _, world := range worlds {
+		sessions, err := listSessions(world)
+		if err == nil && args["session"] != "" {
+			var session *sessionInfo
+			session, err = findSession(world, args["session"])
+			if err != nil && len(worlds) > 1 {
+				// not every world has had that many runs
+				continue
+			}
+			sessions = []*sessionInfo{session}
+		}
+		if err != nil {
+			return "ERROR: " + err.Error()
+		}
+		found, count, err := searchLogs(world, sessions, filter)
+		if err != nil {
+			fmt.Println(err)
+			return "ERROR: could not search the logs of _" + world + "_"
+		}
+		matches = append(matches, found...)
+		total += count
+	}
+	if total == 0 {
+		return "NOTHING IN THE LOGS MATCHES"
+	}
+	sort.SliceStable(matches, func(i, j int) bool { return matches[i].at.Before(matches[j].at) })
+	if len(matches) > maxSearchMatches {
+		matches = matches[len(matches)-maxSearchMatches:]
+	}
+
+	resp := fmt.Sprintf("%d MATCHES", total)
+	if total > len(matches) {
+		resp += fmt.Sprintf(", SHOWING THE LATEST %d", len(matches))
+	}
+	for _, match := range matches {
+		resp += "\n\n" + match.at.Format("Jan 2 15:04:05") + " in _" + match.world + "_ (session " + match.session + ")"
+		resp += "\n```\n" + strings.Join(match.lines, "\n") + "\n```"
+	}
+	return resp
+}
+
 var logsServerRequestAction = func(m *manager, args map[string]string) string {
-	if m.state == running && m.server != nil {
-		return "ERROR: cannot get logs - server is running; stop and try again to see logs"
+	if world, ok := args["world"]; ok {
+		args["_unnamed"] = world
+	}
+	_, grep := args["grep"]
+	_, level := args["level"]
+	_, since := args["since"]
+	if grep || level || since {
+		return searchLogsAction(m, args)
 	}
-	l, err := ioutil.ReadFile("bb-logs")
+
+	s, errMsg := resolveServer(m, args, "logs")
+	if errMsg != "" {
+		return errMsg
+	}
+	world := args["_unnamed"]
+	if s != nil {
+		world = s.worldName
+	}
+
+	session, err := findSession(world, args["session"])
+	if err != nil {
+		return "ERROR: " + err.Error()
+	}
+	path, err := sessionLogPath(world, session.ID)
+	if err != nil {
+		fmt.Println(err)
+		return "ERROR: could not find the logs of _" + world + "_"
+	}
+	l, err := ioutil.ReadFile(path)
 	if err != nil {
 		fmt.Println("AH ERROR", err)
 	}
@@ -100,8 +350,173 @@ var logsServerRequestAction = func(m *manager, args map[string]string) string {
 	return utils.ReadLastLines(l, logLimit, logOffset)
 }
 
+var tailServerRequestAction = func(m *manager, args map[string]string) string {
+	if args["_unnamed"] == "stop" {
+		stopping := make([]string, 0)
+		for world, tail := range m.tails {
+			if requested, ok := args["world"]; ok && requested != world {
+				continue
+			}
+			close(tail.stop)
+			delete(m.tails, world)
+			stopping = append(stopping, "_"+world+"_")
+		}
+		if len(stopping) == 0 {
+			return "ERROR: nothing is being tailed"
+		}
+		sort.Strings(stopping)
+		return "STOPPED TAILING " + strings.Join(stopping, ", ")
+	}
+
+	if world, ok := args["world"]; ok {
+		args["_unnamed"] = world
+	}
+	s, errMsg := resolveServer(m, args, "tail")
+	if errMsg != "" {
+		return errMsg
+	}
+	if s == nil || !s.active() || s.state == stopping || s.state == stoppingForcefully {
+		return "ERROR: that server isn't running. start it and try again"
+	}
+	world := s.worldName
+	if _, ok := m.tails[world]; ok {
+		return "ERROR: _" + world + "_ is already being tailed. stop it with \"!bb tail stop\""
+	}
+
+	channel := m.tailChannel
+	if channel == "" {
+		channel = args["_channel"]
+	}
+	tail := &logTail{world: world, channel: channel, stop: make(chan struct{})}
+	m.tails[world] = tail
+	go tailConsole(m.serverResponses, m.discord, s, tail)
+
+	if channel != args["_channel"] {
+		return "TAILING _" + world + "_ IN <#" + channel + ">. STOP WITH \"!bb tail stop\""
+	}
+	return "TAILING _" + world + "_ HERE. STOP WITH \"!bb tail stop\""
+}
+
+var notifyServerRequestAction = func(m *manager, args map[string]string) string {
+	guildID := args["_guild"]
+	if guildID == "" || guildID == "0" {
+		return "ERROR: notifications are set per discord server, so ask from a channel in one"
+	}
+
+	m.notify.lock.Lock()
+	defer m.notify.lock.Unlock()
+	guild, ok := m.notify.guilds[guildID]
+	if !ok {
+		guild = &guildNotify{}
+	}
+
+	_, changingEnabled := args["enabled"]
+	_, changingQuiet := args["quiet"]
+	if changingEnabled || changingQuiet {
+		updated := *guild
+		if enabled, ok := args["enabled"]; ok {
+			parsed, err := strconv.ParseBool(enabled)
+			if err != nil {
+				return "ERROR: enabled must be true or false"
+			}
+			updated.Enabled = parsed
+			// announcements go wherever they were turned on from
+			updated.ChannelID = args["_channel"]
+		}
+		if quiet, ok := args["quiet"]; ok {
+			start, end, err := parseQuietHours(quiet)
+			if err != nil {
+				return "ERROR: " + err.Error()
+			}
+			updated.QuietStart, updated.QuietEnd = start, end
+		}
+		m.notify.guilds[guildID] = &updated
+		err := m.notify.save()
+		if err != nil {
+			fmt.Println(err)
+			return "ERROR: could not save the notification settings"
+		}
+		guild = &updated
+	}
+
+	if !guild.Enabled {
+		return "JOIN AND LEAVE NOTIFICATIONS ARE OFF. TURN THEM ON FOR THIS CHANNEL WITH \"!bb notify -enabled=true\""
+	}
+	return "JOIN AND LEAVE NOTIFICATIONS ARE ON, IN <#" + guild.ChannelID + ">. QUIET HOURS: " + guild.describeQuiet()
+}
+
+var sessionsServerRequestAction = func(m *manager, args map[string]string) string {
+	worlds := make([]string, 0)
+	if world, ok := args["_unnamed"]; ok {
+		if !worldExists(world) {
+			return "ERROR: requested world is not valid. please supply an existing world"
+		}
+		worlds = append(worlds, world)
+	} else {
+		all, err := getWorlds()
+		if err != nil {
+			fmt.Println(err)
+			return "ERROR: could not list the worlds"
+		}
+		for _, world := range all {
+			worlds = append(worlds, world.name)
+		}
+	}
+
+	limit := 10
+	if parsed, _ := strconv.Atoi(args["l"]); parsed > 0 {
+		limit = parsed
+	}
+
+	type numberedSession struct {
+		*sessionInfo
+		n int
+	}
+	sessions := make([]numberedSession, 0)
+	for _, world := range worlds {
+		worldSessions, err := listSessions(world)
+		if err != nil {
+			fmt.Println(err)
+			continue
+		}
+		for i, session := range worldSessions {
+			sessions = append(sessions, numberedSession{session, i + 1})
+		}
+	}
+	if len(sessions) == 0 {
+		return "NO SESSIONS YET"
+	}
+	sort.Slice(sessions, func(i, j int) bool { return sessions[i].StartedOn.After(sessions[j].StartedOn) })
+	if len(sessions) > limit {
+		sessions = sessions[:limit]
+	}
+
+	resp := "RECENT SESSIONS:\n"
+	for _, session := range sessions {
+		resp += fmt.Sprintf("\n%s #%d (%s): started %s", session.World, session.n, session.ID, session.StartedOn.Format("Jan 2 15:04"))
+		if session.EndedOn == nil {
+			if s, ok := m.servers[session.World]; ok && s.active() && sessionID(s.startedOn) == session.ID {
+				resp += ", still going"
+			} else {
+				resp += ", never finished"
+			}
+			continue
+		}
+		resp += ", ran " + utils.FormatDuration(session.EndedOn.Sub(session.StartedOn)) + ", " + session.Exit
+	}
+	resp += "\n\nRead one with \"!bb logs _world_ -session=_#_\""
+	return resp
+}
+
 var addressServerRequestAction = func(m *manager, args map[string]string) string {
-	return "SERVER LISTENING FROM " + os.Getenv("PUBLIC_DNS")
+	s, errMsg := resolveServer(m, args, "address")
+	if errMsg != "" {
+		return errMsg
+	}
+	if s == nil || !s.active() {
+		return "ERROR: server is not running; it has no address"
+	}
+	return "SERVER LISTENING FROM " + net.JoinHostPort(os.Getenv("PUBLIC_DNS"), strconv.Itoa(s.port))
 }
 
 var helpServerRequestAction = func(m *manager, args map[string]string) string {
@@ -116,40 +531,211 @@ COMMANDS`
 	return helpText
 }
 
-var createServerRequestAction = func(m *manager, args map[string]string) string {
-	if m.state != idle && m.state != crashed {
-		return "ERROR: cannot create server while running. stop server and try again"
-	}
+var worldNameRegexp = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_-]*$`)
 
-	name, ok := args["name"]
-	if !ok || name == "" {
+// validateNewWorldName checks that a name can be given to a new world, returning an error message if it can't
+func validateNewWorldName(m *manager, name string) string {
+	if name == "" {
 		return "ERROR: world name is missing. please supply with the \"name\" option. e.g. -name=_my-new-world_"
 	}
-	valid := true
-	worlds, _ := getWorlds()
-	for _, world := range worlds {
-		if world.name == name {
-			valid = false
-			break
-		}
+	if !worldNameRegexp.MatchString(name) {
+		return "ERROR: world names can only have letters, numbers, dashes and underscores"
 	}
-	if !valid {
+	if worldExists(name) {
 		return "ERROR: world \"" + name + "\" already exists. pick a new name"
 	}
+	// a deleted or archived world leaves its backups and sessions behind, which a new world of the same name would
+	// pick up as its own
+	for _, dir := range []string{backupsDirName, sessionsDirName} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err == nil {
+			return "ERROR: there's an old world called \"" + name + "\" whose files are still in " + dir + "/" + name + ". pick a new name"
+		}
+	}
+	if _, busy := m.busy[name]; busy {
+		return "ERROR: world \"" + name + "\" is already in the works. pick a new name"
+	}
+	return ""
+}
 
-	mode, ok := args["mode"]
-	if !ok {
+// createOptions maps the options of the create command onto the server.properties keys they set
+var createOptions = map[string]string{
+	"mode":       "gamemode",
+	"seed":       "level-seed",
+	"difficulty": "difficulty",
+	"type":       "level-type",
+	"hardcore":   "hardcore",
+	"maxplayers": "max-players",
+}
+
+// levelTypes are the level types the create command accepts, which the server knows by namespaced ids
+var levelTypes = []string{"normal", "flat", "large_biomes", "amplified"}
+
+// createProperties collects the server.properties a new world should be created with from the create command's
+// options, returning an error message if any of them aren't valid
+func createProperties(args map[string]string) (map[string]string, string) {
+	properties := make(map[string]string)
+	for arg, value := range args {
+		if strings.HasPrefix(arg, "prop:") {
+			properties[strings.TrimPrefix(arg, "prop:")] = value
+		}
+	}
+	// the named options win over the generic ones
+	for option, key := range createOptions {
+		if value, ok := args[option]; ok {
+			properties[key] = value
+		}
+	}
+
+	if levelType, ok := args["type"]; ok {
+		valid := false
+		for _, allowed := range levelTypes {
+			if levelType == allowed {
+				valid = true
+				break
+			}
+		}
+		if !valid {
+			return nil, "ERROR: type is not valid. options are " + strings.Join(levelTypes, ", ")
+		}
+		properties["level-type"] = "minecraft:" + levelType
+	}
+
+	for key, value := range properties {
+		err := validateProperty(key, value)
+		if err != nil {
+			return nil, "ERROR: " + err.Error()
+		}
+	}
+	return properties, ""
+}
+
+var createServerRequestAction = func(m *manager, args map[string]string) string {
+	name := args["name"]
+	if errMsg := validateNewWorldName(m, name); errMsg != "" {
+		return errMsg
+	}
+
+	if _, ok := args["mode"]; !ok {
 		return "ERROR: mode is missing. please supply with the \"mode\" option. e.g. -mode=creative"
 	}
-	if mode != "creative" && mode != "survival" {
-		return "ERROR: mode is not valid. options are \"creative\" and \"survival\""
+	properties, errMsg := createProperties(args)
+	if errMsg != "" {
+		return errMsg
 	}
 
-	go createWorld(m.serverResponses, name, mode)
+	version := args["version"]
+	if version != "" && !jarExists(version) {
+		return "ERROR: there's no server jar called \"" + version + "\". see the \"versions\" command for the ones there are"
+	}
+
+	go createWorld(m.serverResponses, name, version, properties)
 
 	return "CREATING WORLD... WAIT FOR CONFIRMATION RESPONSE BEFORE STARTING"
 }
 
+var importServerRequestAction = func(m *manager, args map[string]string) string {
+	name := args["name"]
+	if errMsg := validateNewWorldName(m, name); errMsg != "" {
+		return errMsg
+	}
+
+	url, ok := args["_attachment"]
+	if !ok {
+		return "ERROR: there's nothing to import. attach the world as a .zip file to the message"
+	}
+	if !strings.HasSuffix(strings.ToLower(args["_attachmentName"]), ".zip") {
+		return "ERROR: the world needs to be attached as a .zip file"
+	}
+	if size, err := strconv.ParseInt(args["_attachmentSize"], 10, 64); err == nil && size > maxImportDownloadSize {
+		return "ERROR: that zip is too big. the limit is " + formatBytes(maxImportDownloadSize)
+	}
+
+	mode, ok := args["mode"]
+	if !ok {
+		mode = "survival"
+	}
+	if err := validateProperty("gamemode", mode); err != nil {
+		return "ERROR: " + err.Error()
+	}
+
+	version := args["version"]
+	if version != "" && !jarExists(version) {
+		return "ERROR: there's no server jar called \"" + version + "\". see the \"versions\" command for the ones there are"
+	}
+
+	m.busy[name] = "being imported"
+	go importWorld(m.serverResponses, name, mode, version, url)
+	return "IMPORTING _" + name + "_... WAIT FOR CONFIRMATION RESPONSE BEFORE STARTING"
+}
+
+var exportServerRequestAction = func(m *manager, args map[string]string) string {
+	world, ok := args["_unnamed"]
+	if !ok {
+		return "ERROR: world name is missing. please supply as an unnamed option after the command. i.e. \"!bb export _my-world_\""
+	}
+	if !worldExists(world) {
+		return "ERROR: requested world is not valid. please supply an existing world"
+	}
+	if doing, busy := m.busy[world]; busy {
+		return "ERROR: _" + world + "_ is " + doing + ". hold your horses"
+	}
+	parts := args["parts"] == "true"
+
+	var console func(command string) (string, error)
+	if s, ok := m.servers[world]; ok && s.active() {
+		if s.state != running {
+			return "ERROR: server for _" + world + "_ is starting or stopping; wait for it to settle before exporting it"
+		}
+		console = s.console
+	}
+
+	m.busy[world] = "being exported"
+	go exportWorld(m.serverResponses, world, console, m.uploadLimit, parts)
+	return "PACKAGING _" + world + "_ FOR DOWNLOAD... HANG TIGHT"
+}
+
+var infoServerRequestAction = func(m *manager, args map[string]string) string {
+	world, ok := args["_unnamed"]
+	if !ok {
+		return "ERROR: world name is missing. please supply as an unnamed option after the command. i.e. \"!bb info _my-world_\""
+	}
+	if !worldExists(world) {
+		return "ERROR: requested world is not valid. please supply an existing world"
+	}
+
+	info, err := readLevelInfo(world)
+	if os.IsNotExist(err) {
+		return "_" + world + "_ hasn't been generated yet. start it once and ask again"
+	}
+	if err != nil {
+		fmt.Println(err)
+		return "ERROR: could not read level.dat of _" + world + "_"
+	}
+
+	orUnknown := func(value string) string {
+		if value == "" {
+			return "unknown"
+		}
+		return value
+	}
+	resp := "_" + world + "_\n"
+	resp += "seed: " + orUnknown(info.seed) + "\n"
+	resp += "version: " + orUnknown(info.gameVersion) + " (data version " + strconv.FormatInt(info.dataVersion, 10) + ")\n"
+	resp += "time: " + describeDayTime(info.dayTime) + "\n"
+	resp += "spawn: " + orUnknown(info.spawn) + "\n"
+	resp += "difficulty: " + orUnknown(info.difficulty)
+	if info.hardcore {
+		resp += " (hardcore)"
+	}
+	resp += "\n"
+	if info.lastPlayed.IsZero() {
+		resp += "last played: never"
+	} else {
+		resp += "last played: " + info.lastPlayed.Format("Jan 2 2006 15:04") + " (" + utils.FormatDuration(time.Since(info.lastPlayed)) + " ago)"
+	}
+	return resp
+}
+
 var listServerRequestAction = func(m *manager, args map[string]string) string {
 	worlds, err := getWorlds()
 	if err != nil {
@@ -157,45 +743,612 @@ var listServerRequestAction = func(m *manager, args map[string]string) string {
 		return "Uh oh. I... uh... could not list the worlds. Doesn't really sound good. But what do I know"
 	}
 
+	sortBy := args["sort"]
+	if sortBy == "" {
+		sortBy = "name"
+	}
+	if sortBy != "name" && sortBy != "size" && sortBy != "recent" {
+		return "ERROR: sort is not valid. options are \"name\", \"size\" and \"recent\""
+	}
+
+	usages := make(map[string]*worldUsage, len(worlds))
+	for _, world := range worlds {
+		usage, err := measureWorld(world.name)
+		if err != nil {
+			fmt.Println("could not measure", world.name, err)
+			usage = &worldUsage{}
+		}
+		usages[world.name] = usage
+	}
+	sort.SliceStable(worlds, func(i, j int) bool {
+		a, b := usages[worlds[i].name], usages[worlds[j].name]
+		switch sortBy {
+		case "size":
+			return a.total > b.total
+		case "recent":
+			return a.lastPlayed.After(b.lastPlayed)
+		}
+		return worlds[i].name < worlds[j].name
+	})
+
+	var totalSize int64
 	resp := "AVAILABLE WORLDS:\n"
 	for _, world := range worlds {
+		usage := usages[world.name]
+		totalSize += usage.total
 		resp += fmt.Sprintf("\n%s (%s)", world.name, world.mode)
+		if s, ok := m.servers[world.name]; ok && s.active() {
+			resp += " - " + strings.ToUpper(stateNames[s.state])
+		}
+		resp += fmt.Sprintf("\n    %s (overworld %s, nether %s, end %s)", formatBytes(usage.total), formatBytes(usage.overworld), formatBytes(usage.nether), formatBytes(usage.end))
+		if usage.lastPlayed.IsZero() {
+			resp += ", never played"
+		} else {
+			resp += ", last played " + utils.FormatDuration(time.Since(usage.lastPlayed)) + " ago"
+		}
 	}
+	resp += "\n\n" + formatBytes(totalSize) + " in all"
 
 	resp += "\n\nStart a world with the \"start\" command i.e. \"!bb start _my-world_\""
 	return resp
 }
 
+var autoRestartServerRequestAction = func(m *manager, args map[string]string) string {
+	world, ok := args["_unnamed"]
+	if !ok {
+		return "ERROR: world name is missing. please supply as an unnamed option after the command. i.e. \"!bb autorestart _my-world_ -enabled=true\""
+	}
+	if !worldExists(world) {
+		return "ERROR: requested world is not valid. please supply an existing world"
+	}
+
+	config, err := readWorldConfig(world)
+	if err != nil {
+		fmt.Println(err)
+		return "ERROR: could not read the settings for world \"" + world + "\""
+	}
+	policy := &config.AutoRestart
+
+	changed := false
+	if enabled, ok := args["enabled"]; ok {
+		parsed, err := strconv.ParseBool(enabled)
+		if err != nil {
+			return "ERROR: enabled must be true or false"
+		}
+		policy.Enabled = parsed
+		changed = true
+	}
+	if retries, ok := args["retries"]; ok {
+		parsed, err := strconv.Atoi(retries)
+		if err != nil || parsed < 1 {
+			return "ERROR: retries must be a positive number"
+		}
+		policy.MaxRetries = parsed
+		changed = true
+	}
+	if limit, ok := args["limit"]; ok {
+		parsed, err := strconv.Atoi(limit)
+		if err != nil || parsed < 1 {
+			return "ERROR: limit must be a positive number"
+		}
+		policy.CrashLimit = parsed
+		changed = true
+	}
+	for flag, field := range map[string]*string{"backoff": &policy.Backoff, "window": &policy.CrashWindow} {
+		if value, ok := args[flag]; ok {
+			if parsed, err := time.ParseDuration(value); err != nil || parsed <= 0 {
+				return "ERROR: " + flag + " must be a duration like 30s or 10m"
+			}
+			*field = value
+			changed = true
+		}
+	}
+
+	if changed {
+		err = writeWorldConfig(world, config)
+		if err != nil {
+			fmt.Println(err)
+			return "ERROR: could not save the settings for world \"" + world + "\""
+		}
+	}
+
+	state := "OFF"
+	if policy.Enabled {
+		state = "ON"
+	}
+	return fmt.Sprintf("AUTO-RESTART FOR _%s_ IS %s\nretries: %d, backoff: %s (doubling, max %s), crash loop: %d crashes in %s",
+		world, state, policy.maxRetries(), policy.backoff(), policy.maxBackoff(), policy.crashLimit(), policy.crashWindow())
+}
+
+var configServerRequestAction = func(m *manager, args map[string]string) string {
+	world, ok := args["_unnamed"]
+	if !ok {
+		return "ERROR: world name is missing. please supply as an unnamed option after the command. i.e. \"!bb config _my-world_\""
+	}
+	if !worldExists(world) {
+		return "ERROR: requested world is not valid. please supply an existing world"
+	}
+
+	config, err := readWorldConfig(world)
+	if err != nil {
+		fmt.Println(err)
+		return "ERROR: could not read the settings for world \"" + world + "\""
+	}
+
+	resp := ""
+	if setting, ok := args["set"]; ok {
+		separator := strings.Index(setting, "=")
+		if separator < 0 {
+			return "ERROR: settings are changed like -set=_key_=_value_. i.e. \"!bb config my-world -set=xmx=4G\""
+		}
+		err = config.set(setting[:separator], setting[separator+1:])
+		if err != nil {
+			return "ERROR: " + err.Error()
+		}
+		err = writeWorldConfig(world, config)
+		if err != nil {
+			fmt.Println(err)
+			return "ERROR: could not save the settings for world \"" + world + "\""
+		}
+		resp = "SETTINGS SAVED."
+		if s, ok := m.servers[world]; ok && s.active() {
+			resp += " THEY'LL KICK IN THE NEXT TIME THE SERVER STARTS."
+		}
+		resp += "\n\n"
+	}
+
+	resp += "SETTINGS FOR _" + world + "_:\n"
+	jar := config.Jar
+	if jar == "" {
+		jar = legacyJarName + " (not pinned)"
+	}
+	resp += "\njar: " + jar
+	resp += "\njava: " + config.java()
+	resp += "\nxms: " + config.minHeap()
+	resp += "\nxmx: " + config.maxHeap()
+	resp += "\nflags: " + strings.Join(config.JVMFlags, " ")
+	resp += "\nargs: " + strings.Join(config.ServerArgs, " ")
+	resp += "\n\nchange one with -set, i.e. \"!bb config " + world + " -set=xmx=4G\". careful with jar: minecraft can't load a world in an older version than it was last played in. flags and args take comma separated lists, and -set=preset=aikar applies aikar's flags"
+	return resp
+}
+
+var propsServerRequestAction = func(m *manager, args map[string]string) string {
+	world, ok := args["_unnamed"]
+	if !ok {
+		return "ERROR: world name is missing. please supply as an unnamed option after the command. i.e. \"!bb props _my-world_\""
+	}
+	if !worldExists(world) {
+		return "ERROR: requested world is not valid. please supply an existing world"
+	}
+	propertiesFile := filepath.Join("bb-worlds", world, "server.properties")
+
+	config, err := readWorldConfig(world)
+	if err != nil {
+		fmt.Println(err)
+		return "ERROR: could not read the settings for world \"" + world + "\""
+	}
+
+	if setting, ok := args["set"]; ok {
+		separator := strings.Index(setting, "=")
+		if separator < 0 {
+			return "ERROR: properties are changed like -set=_key_=_value_. i.e. \"!bb props my-world -set=difficulty=hard\""
+		}
+		key, value := setting[:separator], setting[separator+1:]
+		err = validateProperty(key, value)
+		if err != nil {
+			return "ERROR: " + err.Error()
+		}
+
+		if s, ok := m.servers[world]; ok && s.active() {
+			// the server only reads its properties when it starts, and writes them back out when it does
+			if config.PendingProperties == nil {
+				config.PendingProperties = make(map[string]string)
+			}
+			config.PendingProperties[key] = value
+			err = writeWorldConfig(world, config)
+			if err != nil {
+				fmt.Println(err)
+				return "ERROR: could not queue the change for world \"" + world + "\""
+			}
+			return "_" + world + "_ IS RUNNING, SO " + key + "=" + value + " WILL BE APPLIED THE NEXT TIME IT STARTS."
+		}
+
+		err = utils.SetNamedValueInTextFile(propertiesFile, key, value)
+		if err != nil {
+			fmt.Println(err)
+			return "ERROR: could not change the properties for world \"" + world + "\""
+		}
+		return "SET " + key + "=" + value + " FOR _" + world + "_."
+	}
+
+	keys, values, err := readProperties(propertiesFile)
+	if err != nil {
+		fmt.Println(err)
+		return "ERROR: could not read the properties for world \"" + world + "\""
+	}
+	sort.Strings(keys)
+
+	resp := "PROPERTIES FOR _" + world + "_:\n"
+	for _, key := range keys {
+		if key == "rcon.password" {
+			continue
+		}
+		resp += "\n" + key + "=" + values[key]
+	}
+	if len(config.PendingProperties) > 0 {
+		resp += "\n\nWAITING FOR THE NEXT START:"
+		for key, value := range config.PendingProperties {
+			resp += "\n" + key + "=" + value
+		}
+	}
+	return resp
+}
+
+var backupServerRequestAction = func(m *manager, args map[string]string) string {
+	world, ok := args["_unnamed"]
+	if !ok {
+		return "ERROR: world name is missing. please supply as an unnamed option after the command. i.e. \"!bb backup _my-world_\""
+	}
+	if !worldExists(world) {
+		return "ERROR: requested world is not valid. please supply an existing world"
+	}
+	if s, ok := m.servers[world]; ok && s.active() && s.state != running {
+		return "ERROR: server for _" + world + "_ is starting or stopping; wait for it to settle before backing it up"
+	}
+	if doing, busy := m.busy[world]; busy {
+		return "ERROR: _" + world + "_ is " + doing + ". hold your horses"
+	}
+	startBackup(m, world, "")
+	return "BACKING UP _" + world + "_... I'LL LET YOU KNOW WHEN IT'S DONE"
+}
+
+var backupsServerRequestAction = func(m *manager, args map[string]string) string {
+	world, ok := args["_unnamed"]
+	if !ok {
+		return "ERROR: world name is missing. please supply as an unnamed option after the command. i.e. \"!bb backups _my-world_\""
+	}
+	if !worldExists(world) {
+		return "ERROR: requested world is not valid. please supply an existing world"
+	}
+
+	backups, err := listBackups(world)
+	if err != nil {
+		fmt.Println(err)
+		return "ERROR: could not list the backups of _" + world + "_"
+	}
+	if len(backups) == 0 {
+		return "_" + world + "_ HAS NO BACKUPS. MAKE ONE WITH \"!bb backup " + world + "\""
+	}
+
+	var total int64
+	resp := "BACKUPS OF _" + world + "_:\n"
+	for _, backup := range backups {
+		total += backup.size
+		resp += fmt.Sprintf("\n%s - %s, %s ago", backup.id, formatBytes(backup.size), utils.FormatDuration(time.Since(backup.time)))
+	}
+	resp += fmt.Sprintf("\n\n%d backups, %s in total", len(backups), formatBytes(total))
+	return resp
+}
+
+var restoreServerRequestAction = func(m *manager, args map[string]string) string {
+	world, ok := args["_unnamed"]
+	if !ok {
+		return "ERROR: world name is missing. please supply as an unnamed option after the command. i.e. \"!bb restore _my-world_ -backup=_id_\""
+	}
+	if !worldExists(world) {
+		return "ERROR: requested world is not valid. please supply an existing world"
+	}
+	id, ok := args["backup"]
+	if !ok || id == "" {
+		return "ERROR: backup is missing. please supply with the \"backup\" option, using an id from \"!bb backups " + world + "\""
+	}
+	backups, err := listBackups(world)
+	if err != nil {
+		return "ERROR: could not read backups of _" + world + "_"
+	}
+	found := false
+	for _, backup := range backups {
+		if backup.id == id {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return "ERROR: _" + world + "_ has no backup " + id + ". see \"!bb backups " + world + "\" for the ones it has"
+	}
+	if doing, busy := m.busy[world]; busy {
+		return "ERROR: _" + world + "_ is " + doing + ". hold your horses"
+	}
+
+	into, ok := args["into"]
+	if ok {
+		if errMsg := validateNewWorldName(m, into); errMsg != "" {
+			return errMsg
+		}
+		m.busy[into] = "being restored"
+	} else {
+		into = world
+		if s, ok := m.servers[world]; ok && s.active() {
+			return "ERROR: _" + world + "_ is running. stop it before restoring over it, or restore into a new world with -into=_new-world_"
+		}
+	}
+
+	m.busy[world] = "being restored"
+	go restoreWorld(m.serverResponses, world, id, into)
+	return "RESTORING BACKUP " + id + " OF _" + world + "_ INTO _" + into + "_... HANG TIGHT"
+}
+
+// checkWorldRemovable picks the world an archive, delete or rename is aimed at, or returns an error message if it doesn't exist
+// or something is using it
+func checkWorldRemovable(m *manager, args map[string]string, command string) (string, string) {
+	world, ok := args["_unnamed"]
+	if !ok {
+		return "", "ERROR: world name is missing. please supply as an unnamed option after the command. i.e. \"!bb " + command + " _my-world_\""
+	}
+	if !worldExists(world) {
+		return "", "ERROR: requested world is not valid. please supply an existing world"
+	}
+	if s, ok := m.servers[world]; ok && s.active() {
+		return "", "ERROR: _" + world + "_ is running. stop it first"
+	}
+	if doing, busy := m.busy[world]; busy {
+		return "", "ERROR: _" + world + "_ is " + doing + ". hold your horses"
+	}
+	return world, ""
+}
+
+var archiveServerRequestAction = func(m *manager, args map[string]string) string {
+	world, errMsg := checkWorldRemovable(m, args, "archive")
+	if errMsg != "" {
+		return errMsg
+	}
+	m.busy[world] = "being archived"
+	go archiveWorld(m.serverResponses, world)
+	return "ARCHIVING _" + world + "_... I'LL LET YOU KNOW WHEN IT'S PUT AWAY"
+}
+
+var deleteServerRequestAction = func(m *manager, args map[string]string) string {
+	world, errMsg := checkWorldRemovable(m, args, "delete")
+	if errMsg != "" {
+		return errMsg
+	}
+
+	confirm, ok := args["confirm"]
+	if !ok {
+		code, err := generateConfirmCode()
+		if err != nil {
+			fmt.Println(err)
+			return "ERROR: could not set up the delete"
+		}
+		m.pendingDeletes[world] = pendingDelete{code: code, expires: time.Now().Add(deleteConfirmTimeout)}
+		return "ARE YOU SURE? THIS CAN'T BE UNDONE. TO DELETE _" + world + "_ FOR GOOD, SAY \"!bb delete " + world + " -confirm=" + code + "\" WITHIN " + deleteConfirmTimeout.String() + ". (\"!bb archive " + world + "\" KEEPS A COPY INSTEAD)"
+	}
+
+	pending, ok := m.pendingDeletes[world]
+	if !ok || time.Now().After(pending.expires) {
+		delete(m.pendingDeletes, world)
+		return "ERROR: there's no delete of _" + world + "_ waiting to be confirmed. ask again with \"!bb delete " + world + "\""
+	}
+	if confirm != pending.code {
+		return "ERROR: that's not the right code. nothing was deleted"
+	}
+
+	err := deleteWorld(world)
+	if err != nil {
+		fmt.Println(err)
+		return "ERROR: could not delete _" + world + "_"
+	}
+	forgetWorld(m, world)
+	return "DELETED _" + world + "_. ITS BACKUPS ARE STILL IN " + backupsDirName + "/" + world + ", SO THE NAME STAYS TAKEN UNTIL THEY'RE CLEARED OUT."
+}
+
+var cloneServerRequestAction = func(m *manager, args map[string]string) string {
+	world, ok := args["_unnamed"]
+	if !ok {
+		return "ERROR: world name is missing. please supply as an unnamed option after the command. i.e. \"!bb clone _my-world_ -name=_my-copy_\""
+	}
+	if !worldExists(world) {
+		return "ERROR: requested world is not valid. please supply an existing world"
+	}
+	name := args["name"]
+	if errMsg := validateNewWorldName(m, name); errMsg != "" {
+		return errMsg
+	}
+	if doing, busy := m.busy[world]; busy {
+		return "ERROR: _" + world + "_ is " + doing + ". hold your horses"
+	}
+
+	var console func(command string) (string, error)
+	if s, ok := m.servers[world]; ok && s.active() {
+		if s.state != running {
+			return "ERROR: server for _" + world + "_ is starting or stopping; wait for it to settle before cloning it"
+		}
+		console = s.console
+	}
+
+	m.busy[world] = "being cloned"
+	m.busy[name] = "being cloned"
+	go cloneWorld(m.serverResponses, world, name, console)
+	return "CLONING _" + world + "_ AS _" + name + "_... I'LL LET YOU KNOW WHEN IT'S DONE"
+}
+
+var renameServerRequestAction = func(m *manager, args map[string]string) string {
+	world, errMsg := checkWorldRemovable(m, args, "rename")
+	if errMsg != "" {
+		return errMsg
+	}
+	name := args["name"]
+	if errMsg := validateNewWorldName(m, name); errMsg != "" {
+		return errMsg
+	}
+
+	m.busy[world] = "being renamed"
+	m.busy[name] = "being renamed"
+	go renameWorld(m.serverResponses, world, name)
+	return "RENAMING _" + world + "_ TO _" + name + "_..."
+}
+
+var versionsServerRequestAction = func(m *manager, args map[string]string) string {
+	jars, err := getJars()
+	if err != nil {
+		fmt.Println(err)
+		return "ERROR: could not list the server jars"
+	}
+
+	pinned := make(map[string][]string)
+	worlds, _ := getWorlds()
+	for _, world := range worlds {
+		config, err := readWorldConfig(world.name)
+		if err != nil {
+			continue
+		}
+		jar := config.Jar
+		if jar == "" {
+			jar = legacyJarName
+		}
+		pinned[jar] = append(pinned[jar], world.name)
+	}
+
+	resp := "SERVER JARS:\n"
+	if len(jars) == 0 {
+		resp += "\n(none yet. drop jars into " + jarsDirName + "/ as _name_.jar)"
+	}
+	for _, jar := range jars {
+		resp += fmt.Sprintf("\n%s - minecraft %s", jar.name, jar.version)
+		if len(pinned[jar.name]) > 0 {
+			resp += " (used by " + strings.Join(pinned[jar.name], ", ") + ")"
+		}
+	}
+	if len(pinned[legacyJarName]) > 0 {
+		version, err := jarVersion(legacyJarName)
+		if err != nil {
+			version = "unknown"
+		}
+		resp += fmt.Sprintf("\n\nunpinned worlds run on %s (minecraft %s): %s", legacyJarName, version, strings.Join(pinned[legacyJarName], ", "))
+	}
+	resp += "\n\nPin a new world with \"!bb create -name=_my-world_ -mode=survival -version=_jar-name_\", or an existing one with \"!bb config _my-world_ -set=jar=_jar-name_\""
+	return resp
+}
+
 var drewServerRequestAction = func(m *manager, args map[string]string) string {
 	return "shut the fuck up drew"
 }
 
 var serverRequestActions = map[defs.ServerRequestOpCode]serverAction{
-	defs.Start:   startServerRequestAction,
-	defs.Stop:    stopServerRequestAction,
-	defs.Kill:    killServerRequestAction,
-	defs.Status:  statusServerRequestAction,
-	defs.Logs:    logsServerRequestAction,
-	defs.Address: addressServerRequestAction,
-	defs.Help:    helpServerRequestAction,
-	defs.Create:  createServerRequestAction,
-	defs.List:    listServerRequestAction,
-	defs.Drew:    drewServerRequestAction,
+	defs.Start:             startServerRequestAction,
+	defs.Stop:              stopServerRequestAction,
+	defs.Save:              saveServerRequestAction,
+	defs.Kill:              killServerRequestAction,
+	defs.Status:            statusServerRequestAction,
+	defs.Logs:              logsServerRequestAction,
+	defs.Address:           addressServerRequestAction,
+	defs.Help:              helpServerRequestAction,
+	defs.Create:            createServerRequestAction,
+	defs.List:              listServerRequestAction,
+	defs.Drew:              drewServerRequestAction,
+	defs.AutoRestartPolicy: autoRestartServerRequestAction,
+	defs.Config:            configServerRequestAction,
+	defs.Versions:          versionsServerRequestAction,
+	defs.Props:             propsServerRequestAction,
+	defs.Backup:            backupServerRequestAction,
+	defs.Backups:           backupsServerRequestAction,
+	defs.Restore:           restoreServerRequestAction,
+	defs.Archive:           archiveServerRequestAction,
+	defs.Delete:            deleteServerRequestAction,
+	defs.Clone:             cloneServerRequestAction,
+	defs.Rename:            renameServerRequestAction,
+	defs.Import:            importServerRequestAction,
+	defs.Export:            exportServerRequestAction,
+	defs.Info:              infoServerRequestAction,
+	defs.Sessions:          sessionsServerRequestAction,
+	defs.Tail:              tailServerRequestAction,
+	defs.Notify:            notifyServerRequestAction,
 }
 
 var startedServerResponseAction = func(m *manager, args map[string]string) string {
-	m.state = running
-	return "SERVER IS READY. BLOC AWAY MY BOIS"
+	s, ok := m.servers[args["world"]]
+	if !ok || s.state != starting {
+		// a server killed or timed out while starting stays stopping, so its exit isn't taken for a crash
+		return ""
+	}
+	s.state = running
+	if s.autoRestarting {
+		s.autoRestarting = false
+		delete(m.restartAttempts, s.worldName)
+		return "AUTO-RESTART SUCCEEDED. SERVER FOR _" + s.worldName + "_ IS READY (STARTED IN " + args["startup"] + "). BLOC AWAY MY BOIS"
+	}
+	if args["reported"] == "" {
+		return "SERVER FOR _" + s.worldName + "_ IS READY (STARTED IN " + args["startup"] + "). BLOC AWAY MY BOIS"
+	}
+	return "SERVER FOR _" + s.worldName + "_ IS READY (STARTED IN " + args["startup"] + ", SERVER CLAIMS " + args["reported"] + "). BLOC AWAY MY BOIS"
 }
 
 var stoppedServerResponseAction = func(m *manager, args map[string]string) string {
-	m.server = nil
-	if m.state != stopping {
-		m.state = crashed
-		return "SHIT. SERVER HAS CRASHED"
+	world := args["world"]
+	s, ok := m.servers[world]
+	if !ok {
+		return ""
+	}
+	if s.state == starting {
+		s.state = crashed
+		endSession(world, s.startedOn, exitStarting)
+		if s.autoRestarting {
+			s.autoRestarting = false
+			return "AUTO-RESTART OF _" + world + "_ FAILED. SERVER EXITED BEFORE IT FINISHED STARTING." + recordCrash(m, world)
+		}
+		return "SHIT. SERVER FOR _" + world + "_ EXITED BEFORE IT FINISHED STARTING. CHECK THE LOGS" + recordCrash(m, world)
+	}
+	if s.state == stoppingForcefully {
+		s.state = stoppedForcefully
+		endSession(world, s.startedOn, exitKilled)
+		return "SERVER FOR _" + world + "_ WAS STOPPED FORCEFULLY. IT MIGHT NOT HAVE SAVED EVERYTHING."
+	}
+	if s.state != stopping {
+		s.state = crashed
+		endSession(world, s.startedOn, exitCrashed)
+		return "SHIT. SERVER FOR _" + world + "_ HAS CRASHED" + recordCrash(m, world)
 	}
-	m.state = idle
-	return "SERVER HAS STOPPED."
+	s.state = idle
+	endSession(world, s.startedOn, exitStopped)
+	if m.backupOnStop {
+		startBackup(m, world, stopBackup)
+	}
+	return "SERVER FOR _" + world + "_ HAS STOPPED."
+}
+
+var stopTimedOutServerResponseAction = func(m *manager, args map[string]string) string {
+	s, ok := m.servers[args["world"]]
+	if !ok || s.state != stopping || s.startedOn.String() != args["startedOn"] {
+		// the server made it out on its own after all
+		return ""
+	}
+	s.state = stoppingForcefully
+	s.kill()
+	return "WARNING: SERVER FOR _" + s.worldName + "_ DID NOT STOP WITHIN " + m.stopTimeout.String() + ". KILLING IT."
+}
+
+var autoRestartServerResponseAction = func(m *manager, args map[string]string) string {
+	world := args["world"]
+	if s, ok := m.servers[world]; !ok || s.state != crashed {
+		// someone already got it going again
+		delete(m.restartAttempts, world)
+		return ""
+	}
+
+	port, ok := freePort(m)
+	if !ok {
+		delete(m.restartAttempts, world)
+		return "AUTO-RESTART OF _" + world + "_ FAILED. ALL SERVER PORTS ARE TAKEN."
+	}
+	s, err := startServer(m.serverResponses, m.events, world, port)
+	if err != nil {
+		fmt.Println(err)
+		delete(m.restartAttempts, world)
+		return "AUTO-RESTART OF _" + world + "_ FAILED. COULD NOT START THE SERVER."
+	}
+	s.autoRestarting = true
+	m.servers[world] = s
+	return "AUTO-RESTARTING WORLD _" + world + "_ (ATTEMPT " + args["attempt"] + "). WAIT FOR START MESSAGE TO JOIN."
 }
 
 var createdWorldSuccessServerResonseAction = func(m *manager, args map[string]string) string {
@@ -207,9 +1360,162 @@ var createdWorldFailureServerResonseAction = func(m *manager, args map[string]st
 	return "ERROR: COULD NOT CREATE WORLD"
 }
 
+var backupSuccessServerResponseAction = func(m *manager, args map[string]string) string {
+	delete(m.busy, args["world"])
+	if args["reason"] == scheduledBackup {
+		// nobody needs to hear about these every hour
+		return ""
+	}
+	size, _ := strconv.ParseInt(args["size"], 10, 64)
+	return "BACKED UP _" + args["world"] + "_ AS " + args["id"] + " (" + formatBytes(size) + ")."
+}
+
+var backupFailureServerResponseAction = func(m *manager, args map[string]string) string {
+	delete(m.busy, args["world"])
+	return "ERROR: COULD NOT BACK UP _" + args["world"] + "_: " + args["error"]
+}
+
+var restoreSuccessServerResponseAction = func(m *manager, args map[string]string) string {
+	delete(m.busy, args["world"])
+	delete(m.busy, args["into"])
+	if args["into"] != args["world"] {
+		return "RESTORED BACKUP " + args["id"] + " OF _" + args["world"] + "_ AS A NEW WORLD, _" + args["into"] + "_."
+	}
+	return "RESTORED BACKUP " + args["id"] + " OF _" + args["world"] + "_. THE WORLD AS IT WAS BEFORE IS SAFE IN " + backupsDirName + "/" + args["world"] + "/" + args["safety"] + "."
+}
+
+var restoreFailureServerResponseAction = func(m *manager, args map[string]string) string {
+	delete(m.busy, args["world"])
+	delete(m.busy, args["into"])
+	return "ERROR: COULD NOT RESTORE BACKUP " + args["id"] + " OF _" + args["world"] + "_: " + args["error"] + ". NOTHING WAS CHANGED."
+}
+
+var archiveSuccessServerResponseAction = func(m *manager, args map[string]string) string {
+	delete(m.busy, args["world"])
+	forgetWorld(m, args["world"])
+	size, _ := strconv.ParseInt(args["size"], 10, 64)
+	return "ARCHIVED _" + args["world"] + "_ AS " + archivesDirName + "/" + args["archive"] + " (" + formatBytes(size) + "). ANY BACKUPS OR SESSIONS IT HAD STAY WHERE THEY ARE, AND KEEP THE NAME TAKEN UNTIL THEY'RE CLEARED OUT."
+}
+
+var archiveFailureServerResponseAction = func(m *manager, args map[string]string) string {
+	delete(m.busy, args["world"])
+	return "ERROR: COULD NOT ARCHIVE _" + args["world"] + "_: " + args["error"]
+}
+
+var cloneSuccessServerResponseAction = func(m *manager, args map[string]string) string {
+	delete(m.busy, args["world"])
+	delete(m.busy, args["name"])
+	return "CLONED _" + args["world"] + "_ AS _" + args["name"] + "_. GO WILD"
+}
+
+var cloneFailureServerResponseAction = func(m *manager, args map[string]string) string {
+	delete(m.busy, args["world"])
+	delete(m.busy, args["name"])
+	return "ERROR: COULD NOT CLONE _" + args["world"] + "_: " + args["error"]
+}
+
+var renameSuccessServerResponseAction = func(m *manager, args map[string]string) string {
+	delete(m.busy, args["world"])
+	delete(m.busy, args["name"])
+	forgetWorld(m, args["world"])
+	if args["backups"] == "stranded" {
+		return "RENAMED _" + args["world"] + "_ TO _" + args["name"] + "_, BUT ITS BACKUPS ARE STILL UNDER " + backupsDirName + "/" + args["world"] + "."
+	}
+	return "RENAMED _" + args["world"] + "_ TO _" + args["name"] + "_."
+}
+
+var renameFailureServerResponseAction = func(m *manager, args map[string]string) string {
+	delete(m.busy, args["world"])
+	delete(m.busy, args["name"])
+	return "ERROR: COULD NOT RENAME _" + args["world"] + "_: " + args["error"]
+}
+
+var importSuccessServerResponseAction = func(m *manager, args map[string]string) string {
+	delete(m.busy, args["name"])
+	return "IMPORTED _" + args["name"] + "_. IT'S READY TO START"
+}
+
+var importFailureServerResponseAction = func(m *manager, args map[string]string) string {
+	delete(m.busy, args["name"])
+	return "ERROR: COULD NOT IMPORT _" + args["name"] + "_: " + args["error"]
+}
+
+var exportSuccessServerResponseAction = func(m *manager, args map[string]string) string {
+	delete(m.busy, args["world"])
+	files := strings.Split(args["files"], "\n")
+	dir := args["dir"]
+	m.reply = &defs.DiscordResponse{
+		Files:   files,
+		Cleanup: func() { os.RemoveAll(dir) },
+	}
+
+	world := args["world"]
+	switch args["kind"] {
+	case overworldExport:
+		return "HERE'S _" + world + "_. IT WAS TOO BIG TO UPLOAD WHOLE, SO THIS IS JUST THE OVERWORLD. ASK FOR \"!bb export " + world + " -parts=true\" TO GET EVERYTHING"
+	case partsExport:
+		return "HERE'S _" + world + "_ IN " + strconv.Itoa(len(files)) + " PARTS. DOWNLOAD THEM ALL AND JOIN THEM IN ORDER, i.e. \"cat " + world + ".zip.* > " + world + ".zip\""
+	}
+	return "HERE'S _" + world + "_."
+}
+
+var exportFailureServerResponseAction = func(m *manager, args map[string]string) string {
+	delete(m.busy, args["world"])
+	return "ERROR: COULD NOT EXPORT _" + args["world"] + "_: " + args["error"]
+}
+
+var tailEndedServerResponseAction = func(m *manager, args map[string]string) string {
+	world := args["world"]
+	tail, ok := m.tails[world]
+	if !ok {
+		return ""
+	}
+	delete(m.tails, world)
+	m.reply = &defs.DiscordResponse{ChannelID: tail.channel}
+	return "STOPPED TAILING _" + world + "_. ITS SESSION ENDED"
+}
+
+var backupCheckServerResponseAction = func(m *manager, args map[string]string) string {
+	if m.backupInterval == 0 {
+		return ""
+	}
+	for world, s := range m.servers {
+		if _, busy := m.busy[world]; busy || s.state != running {
+			continue
+		}
+		backups, err := listBackups(world)
+		if err != nil {
+			fmt.Println(err)
+			continue
+		}
+		if len(backups) == 0 || time.Since(backups[0].time) >= m.backupInterval {
+			startBackup(m, world, scheduledBackup)
+		}
+	}
+	return ""
+}
+
 var serverResponseActions = map[defs.ServerResponseOpCode]serverAction{
 	defs.Started:            startedServerResponseAction,
 	defs.Stopped:            stoppedServerResponseAction,
+	defs.StopTimedOut:       stopTimedOutServerResponseAction,
+	defs.AutoRestart:        autoRestartServerResponseAction,
 	defs.CreateWorldFailure: createdWorldFailureServerResonseAction,
 	defs.CreateWorldSuccess: createdWorldSuccessServerResonseAction,
+	defs.BackupSuccess:      backupSuccessServerResponseAction,
+	defs.BackupFailure:      backupFailureServerResponseAction,
+	defs.BackupCheck:        backupCheckServerResponseAction,
+	defs.RestoreSuccess:     restoreSuccessServerResponseAction,
+	defs.RestoreFailure:     restoreFailureServerResponseAction,
+	defs.ArchiveSuccess:     archiveSuccessServerResponseAction,
+	defs.ArchiveFailure:     archiveFailureServerResponseAction,
+	defs.CloneSuccess:       cloneSuccessServerResponseAction,
+	defs.CloneFailure:       cloneFailureServerResponseAction,
+	defs.RenameSuccess:      renameSuccessServerResponseAction,
+	defs.RenameFailure:      renameFailureServerResponseAction,
+	defs.ImportSuccess:      importSuccessServerResponseAction,
+	defs.ImportFailure:      importFailureServerResponseAction,
+	defs.ExportSuccess:      exportSuccessServerResponseAction,
+	defs.ExportFailure:      exportFailureServerResponseAction,
+	defs.TailEnded:          tailEndedServerResponseAction,
 }
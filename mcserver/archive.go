@@ -0,0 +1,99 @@
+package mcserver
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/matthewdavidrodgers/dbot-mk2/defs"
+	"github.com/matthewdavidrodgers/dbot-mk2/utils"
+)
+
+// archivesDirName is the store of worlds that have been put away, kept as bb-archives/<world>-<id>.tar.gz
+const archivesDirName = "bb-archives"
+
+// deleteConfirmTimeout is how long a delete waits for its confirmation before it has to be asked for again
+const deleteConfirmTimeout = time.Minute
+
+// pendingDelete is a delete that's been asked for but not yet confirmed
+type pendingDelete struct {
+	code    string
+	expires time.Time
+}
+
+// archiveWorld compresses a world's directory into the archive store and removes it from bb-worlds
+func archiveWorld(notify chan<- *defs.ServerResponseOp, world string) {
+	fail := func(err error) {
+		fmt.Println("archive of", world, "failed:", err)
+		notify <- &defs.ServerResponseOp{
+			Code: defs.ArchiveFailure,
+			Args: map[string]string{"world": world, "error": err.Error()},
+		}
+	}
+
+	pwd, err := os.Getwd()
+	if err != nil {
+		fail(err)
+		return
+	}
+	archiveDir := filepath.Join(pwd, archivesDirName)
+	err = os.MkdirAll(archiveDir, 0755)
+	if err != nil {
+		fail(err)
+		return
+	}
+
+	name := world + "-" + time.Now().Format(backupIDFormat) + ".tar.gz"
+	destination := filepath.Join(archiveDir, name)
+	err = utils.TarGzDir(filepath.Join(pwd, "bb-worlds", world), destination)
+	if err != nil {
+		fail(err)
+		return
+	}
+
+	var size int64
+	if info, err := os.Stat(destination); err == nil {
+		size = info.Size()
+	}
+
+	err = os.RemoveAll(filepath.Join(pwd, "bb-worlds", world))
+	if err != nil {
+		fail(err)
+		return
+	}
+
+	notify <- &defs.ServerResponseOp{
+		Code: defs.ArchiveSuccess,
+		Args: map[string]string{"world": world, "archive": name, "size": strconv.FormatInt(size, 10)},
+	}
+}
+
+// deleteWorld removes a world's directory for good. its backups are left alone
+func deleteWorld(world string) error {
+	pwd, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+	return os.RemoveAll(filepath.Join(pwd, "bb-worlds", world))
+}
+
+// forgetWorld drops everything the manager remembers about a world that's no longer in bb-worlds
+func forgetWorld(m *manager, world string) {
+	delete(m.servers, world)
+	delete(m.crashes, world)
+	delete(m.restartAttempts, world)
+	delete(m.pendingDeletes, world)
+}
+
+func generateConfirmCode() (string, error) {
+	bytes := make([]byte, 2)
+	_, err := rand.Read(bytes)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(bytes), nil
+}
@@ -0,0 +1,246 @@
+package mcserver
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/matthewdavidrodgers/dbot-mk2/defs"
+	"github.com/matthewdavidrodgers/dbot-mk2/utils"
+)
+
+// backupsDirName is the store of world backups, kept as bb-backups/<world>/<id>.tar.gz
+const backupsDirName = "bb-backups"
+
+// backupIDFormat turns a backup's time into its id, which sorts in the order the backups were made
+const backupIDFormat = "20060102-150405"
+
+// reasons a backup was made, other than someone asking for it
+const (
+	scheduledBackup = "scheduled"
+	stopBackup      = "stop"
+)
+
+// defaults for the backup schedule and retention, unless the BB_BACKUP_* environment variables say otherwise
+const (
+	defaultBackupInterval = time.Hour
+	defaultKeepHourly     = 24
+	defaultKeepDaily      = 7
+	defaultKeepWeekly     = 4
+
+	// how often the scheduler checks whether a running world is due for a backup
+	backupCheckInterval = time.Minute
+)
+
+// backupWorld archives a world's directory into the backup store, then prunes the store by the retention policy.
+// console is the running server's console, or nil if the world isn't running; when it's given, the server flushes
+// everything to disk and stops saving until the archive is done, so the snapshot is consistent
+func backupWorld(notify chan<- *defs.ServerResponseOp, world string, console func(command string) (string, error), reason string, retention retentionPolicy) {
+	fail := func(err error) {
+		fmt.Println("backup of", world, "failed:", err)
+		notify <- &defs.ServerResponseOp{
+			Code: defs.BackupFailure,
+			Args: map[string]string{"world": world, "error": err.Error(), "reason": reason},
+		}
+	}
+
+	pwd, err := os.Getwd()
+	if err != nil {
+		fail(err)
+		return
+	}
+	worldDir := filepath.Join(pwd, "bb-worlds", world)
+	backupDir := filepath.Join(pwd, backupsDirName, world)
+	err = os.MkdirAll(backupDir, 0755)
+	if err != nil {
+		fail(err)
+		return
+	}
+
+	id := time.Now().Format(backupIDFormat)
+	destination := filepath.Join(backupDir, id+".tar.gz")
+	err = withSavingPaused(console, func() error {
+		return utils.TarGzDir(worldDir, destination)
+	})
+	if err != nil {
+		fail(err)
+		return
+	}
+
+	var size int64
+	if info, err := os.Stat(destination); err == nil {
+		size = info.Size()
+	}
+
+	err = pruneBackups(world, retention)
+	if err != nil {
+		// the backup itself is fine, so don't make a fuss
+		fmt.Println("could not prune backups of", world, err)
+	}
+
+	notify <- &defs.ServerResponseOp{
+		Code: defs.BackupSuccess,
+		Args: map[string]string{"world": world, "id": id, "size": strconv.FormatInt(size, 10), "reason": reason},
+	}
+}
+
+// withSavingPaused runs fn while a running server's world is flushed to disk and left alone, so whatever fn reads
+// from the world directory is consistent. console is nil if the world isn't running, in which case fn just runs
+func withSavingPaused(console func(command string) (string, error), fn func() error) error {
+	if console == nil {
+		return fn()
+	}
+	_, err := console("save-off")
+	if err != nil {
+		return err
+	}
+	defer console("save-on")
+	_, err = console("save-all flush")
+	if err != nil {
+		return err
+	}
+	return fn()
+}
+
+// scheduleBackups asks the manager to check for worlds due a backup, forever
+func scheduleBackups(notify chan<- *defs.ServerResponseOp) {
+	for range time.Tick(backupCheckInterval) {
+		notify <- &defs.ServerResponseOp{Code: defs.BackupCheck}
+	}
+}
+
+// startBackup kicks off a backup of a world, unless something else is already underway on it
+func startBackup(m *manager, world string, reason string) bool {
+	if _, busy := m.busy[world]; busy {
+		return false
+	}
+
+	var console func(command string) (string, error)
+	if s, ok := m.servers[world]; ok && s.state == running {
+		console = s.console
+	}
+
+	m.busy[world] = "being backed up"
+	go backupWorld(m.serverResponses, world, console, reason, m.retention)
+	return true
+}
+
+// formatBytes renders a size in bytes the way a human would want to read it
+func formatBytes(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%dB", size)
+	}
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f%cB", float64(size)/float64(div), "KMGTPE"[exp])
+}
+
+// backupInfo describes an archive in the backup store
+type backupInfo struct {
+	id   string
+	path string
+	time time.Time
+	size int64
+}
+
+// listBackups lists a world's backups, newest first
+func listBackups(world string) ([]backupInfo, error) {
+	pwd, err := os.Getwd()
+	if err != nil {
+		return nil, err
+	}
+
+	dir, err := ioutil.ReadDir(filepath.Join(pwd, backupsDirName, world))
+	if os.IsNotExist(err) {
+		return []backupInfo{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	backups := make([]backupInfo, 0)
+	for _, file := range dir {
+		if file.IsDir() || !strings.HasSuffix(file.Name(), ".tar.gz") {
+			continue
+		}
+		id := strings.TrimSuffix(file.Name(), ".tar.gz")
+		madeAt, err := time.ParseInLocation(backupIDFormat, id, time.Local)
+		if err != nil {
+			continue
+		}
+		backups = append(backups, backupInfo{
+			id:   id,
+			path: filepath.Join(pwd, backupsDirName, world, file.Name()),
+			time: madeAt,
+			size: file.Size(),
+		})
+	}
+	sort.Slice(backups, func(i, j int) bool { return backups[i].time.After(backups[j].time) })
+	return backups, nil
+}
+
+// retentionPolicy is a grandfather-father-son policy: the newest backup of each of the last hourly hours, daily days
+// and weekly weeks is kept, along with the newest backup overall
+type retentionPolicy struct {
+	hourly int
+	daily  int
+	weekly int
+}
+
+// backupsToKeep picks which of a world's backups (newest first) the retention policy keeps
+func backupsToKeep(backups []backupInfo, policy retentionPolicy) map[string]bool {
+	keep := make(map[string]bool)
+	if len(backups) > 0 {
+		keep[backups[0].id] = true
+	}
+
+	keepNewestPerBucket := func(count int, bucket func(t time.Time) string) {
+		seen := make(map[string]bool)
+		for _, backup := range backups {
+			if len(seen) >= count {
+				return
+			}
+			key := bucket(backup.time)
+			if !seen[key] {
+				seen[key] = true
+				keep[backup.id] = true
+			}
+		}
+	}
+	keepNewestPerBucket(policy.hourly, func(t time.Time) string { return t.Format("2006010215") })
+	keepNewestPerBucket(policy.daily, func(t time.Time) string { return t.Format("20060102") })
+	keepNewestPerBucket(policy.weekly, func(t time.Time) string {
+		year, week := t.ISOWeek()
+		return fmt.Sprintf("%d-%d", year, week)
+	})
+	return keep
+}
+
+// pruneBackups deletes the backups of a world the retention policy doesn't keep
+func pruneBackups(world string, policy retentionPolicy) error {
+	backups, err := listBackups(world)
+	if err != nil {
+		return err
+	}
+	keep := backupsToKeep(backups, policy)
+	for _, backup := range backups {
+		if keep[backup.id] {
+			continue
+		}
+		err = os.Remove(backup.path)
+		if err != nil {
+			return err
+		}
+		fmt.Println("pruned backup", backup.id, "of", world)
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+package mcserver
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestBackupsToKeep(t *testing.T) {
+	at := func(value string) time.Time {
+		parsed, err := time.ParseInLocation("2006-01-02 15:04", value, time.Local)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return parsed
+	}
+
+	cases := []struct {
+		name    string
+		backups []string
+		policy  retentionPolicy
+		keep    []string
+	}{
+		{
+			name:    "nothing to keep",
+			backups: []string{},
+			policy:  retentionPolicy{hourly: 24, daily: 7, weekly: 4},
+			keep:    []string{},
+		},
+		{
+			name:    "newest is always kept",
+			backups: []string{"2024-03-05 10:50", "2024-03-05 09:30"},
+			policy:  retentionPolicy{},
+			keep:    []string{"2024-03-05 10:50"},
+		},
+		{
+			name:    "newest of each hour",
+			backups: []string{"2024-03-05 10:50", "2024-03-05 10:10", "2024-03-05 09:30", "2024-03-05 08:00"},
+			policy:  retentionPolicy{hourly: 2},
+			keep:    []string{"2024-03-05 10:50", "2024-03-05 09:30"},
+		},
+		{
+			name:    "newest of each day",
+			backups: []string{"2024-03-05 10:00", "2024-03-05 01:00", "2024-03-04 23:00", "2024-03-04 12:00", "2024-03-03 12:00"},
+			policy:  retentionPolicy{daily: 2},
+			keep:    []string{"2024-03-05 10:00", "2024-03-04 23:00"},
+		},
+		{
+			name:    "buckets overlap",
+			backups: []string{"2024-03-05 10:00", "2024-03-05 09:00", "2024-03-04 23:00", "2024-03-04 22:00"},
+			policy:  retentionPolicy{hourly: 2, daily: 2},
+			keep:    []string{"2024-03-05 10:00", "2024-03-05 09:00", "2024-03-04 23:00"},
+		},
+		{
+			name: "iso weeks start on monday",
+			// sunday the 7th is the end of week 1, monday the 8th starts week 2
+			backups: []string{"2024-01-08 09:00", "2024-01-07 20:00", "2024-01-01 09:00"},
+			policy:  retentionPolicy{weekly: 2},
+			keep:    []string{"2024-01-08 09:00", "2024-01-07 20:00"},
+		},
+		{
+			name: "iso weeks span new year",
+			// the 30th of december 2024 to the 5th of january 2025 is all week 1 of 2025
+			backups: []string{"2025-01-01 09:00", "2024-12-31 09:00", "2024-12-29 09:00"},
+			policy:  retentionPolicy{weekly: 2},
+			keep:    []string{"2025-01-01 09:00", "2024-12-29 09:00"},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			backups := make([]backupInfo, 0)
+			for _, value := range c.backups {
+				madeAt := at(value)
+				backups = append(backups, backupInfo{id: madeAt.Format(backupIDFormat), time: madeAt})
+			}
+
+			kept := make([]string, 0)
+			for id := range backupsToKeep(backups, c.policy) {
+				kept = append(kept, id)
+			}
+			want := make([]string, 0)
+			for _, value := range c.keep {
+				want = append(want, at(value).Format(backupIDFormat))
+			}
+			sort.Strings(kept)
+			sort.Strings(want)
+			if !reflect.DeepEqual(kept, want) {
+				t.Errorf("kept %v, want %v", kept, want)
+			}
+		})
+	}
+}
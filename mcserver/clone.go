@@ -0,0 +1,86 @@
+package mcserver
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/matthewdavidrodgers/dbot-mk2/defs"
+	"github.com/matthewdavidrodgers/dbot-mk2/utils"
+)
+
+// cloneWorld copies a world's directory, settings and all, under a new name. console is the running server's
+// console, or nil if the world isn't running; when it's given, saving is paused so the copy is consistent
+func cloneWorld(notify chan<- *defs.ServerResponseOp, world string, name string, console func(command string) (string, error)) {
+	args := map[string]string{"world": world, "name": name}
+	fail := func(err error) {
+		fmt.Println("clone of", world, "failed:", err)
+		args["error"] = err.Error()
+		notify <- &defs.ServerResponseOp{Code: defs.CloneFailure, Args: args}
+	}
+
+	pwd, err := os.Getwd()
+	if err != nil {
+		fail(err)
+		return
+	}
+	source := filepath.Join(pwd, "bb-worlds", world)
+	destination := filepath.Join(pwd, "bb-worlds", name)
+
+	err = withSavingPaused(console, func() error {
+		return utils.CopyDir(source, destination)
+	})
+	if err == nil {
+		// the copy shouldn't share its original's rcon password
+		err = configureRcon(filepath.Join(destination, "server.properties"))
+	}
+	if err != nil {
+		os.RemoveAll(destination)
+		fail(err)
+		return
+	}
+
+	notify <- &defs.ServerResponseOp{Code: defs.CloneSuccess, Args: args}
+}
+
+// renameWorld moves a world's directory, and its backups and logs, to a new name
+func renameWorld(notify chan<- *defs.ServerResponseOp, world string, name string) {
+	args := map[string]string{"world": world, "name": name}
+	fail := func(err error) {
+		fmt.Println("rename of", world, "failed:", err)
+		args["error"] = err.Error()
+		notify <- &defs.ServerResponseOp{Code: defs.RenameFailure, Args: args}
+	}
+
+	pwd, err := os.Getwd()
+	if err != nil {
+		fail(err)
+		return
+	}
+
+	err = os.Rename(filepath.Join(pwd, "bb-worlds", world), filepath.Join(pwd, "bb-worlds", name))
+	if err != nil {
+		fail(err)
+		return
+	}
+
+	oldBackups := filepath.Join(pwd, backupsDirName, world)
+	if _, err := os.Stat(oldBackups); err == nil {
+		err = os.Rename(oldBackups, filepath.Join(pwd, backupsDirName, name))
+		if err != nil {
+			// the world itself moved fine, so don't undo that over its backups
+			fmt.Println("could not move backups of", world, err)
+			args["backups"] = "stranded"
+		}
+	}
+	oldSessions := filepath.Join(pwd, sessionsDirName, world)
+	if _, err := os.Stat(oldSessions); err == nil {
+		err = os.Rename(oldSessions, filepath.Join(pwd, sessionsDirName, name))
+		if err != nil {
+			// the old logs are only history
+			fmt.Println("could not move sessions of", world, err)
+		}
+	}
+
+	notify <- &defs.ServerResponseOp{Code: defs.RenameSuccess, Args: args}
+}
@@ -0,0 +1,36 @@
+package mcserver
+
+import (
+	"bufio"
+	"io"
+	"regexp"
+	"strings"
+)
+
+var doneLineRegexp = regexp.MustCompile(`Done \(([0-9.]+)s\)! For help, type`)
+
+// watchConsole copies the server's console output line by line to the log, handing each line to onLine. it keeps
+// reading until the console is closed, so the server never blocks on a full pipe
+func watchConsole(console io.Reader, log io.Writer, onLine func(line string)) {
+	reader := bufio.NewReader(console)
+	for {
+		line, err := reader.ReadString('\n')
+		if len(line) > 0 {
+			log.Write([]byte(line))
+			onLine(strings.TrimRight(line, "\r\n"))
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// parseDoneLine checks for the line the vanilla server prints once it's ready for players, returning the startup
+// time it reports
+func parseDoneLine(line string) (string, bool) {
+	matches := doneLineRegexp.FindStringSubmatch(line)
+	if len(matches) < 2 {
+		return "", false
+	}
+	return matches[1] + "s", true
+}
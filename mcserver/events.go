@@ -0,0 +1,216 @@
+package mcserver
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/matthewdavidrodgers/dbot-mk2/defs"
+)
+
+// eventBufferSize is how many events a subscriber can fall behind by before it starts missing them
+const eventBufferSize = 64
+
+// eventBus hands the game events of every server to whatever has subscribed to them
+type eventBus struct {
+	lock        sync.Mutex
+	subscribers map[int]chan *defs.GameEvent
+	next        int
+}
+
+func newEventBus() *eventBus {
+	return &eventBus{subscribers: make(map[int]chan *defs.GameEvent)}
+}
+
+// subscribe starts receiving events, until cancel is called
+func (b *eventBus) subscribe() (<-chan *defs.GameEvent, func()) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	id := b.next
+	b.next++
+	events := make(chan *defs.GameEvent, eventBufferSize)
+	b.subscribers[id] = events
+	return events, func() {
+		b.lock.Lock()
+		defer b.lock.Unlock()
+		if _, ok := b.subscribers[id]; ok {
+			delete(b.subscribers, id)
+			close(events)
+		}
+	}
+}
+
+// publish sends an event to every subscriber. a subscriber that isn't keeping up misses it, rather than holding up
+// the server's console
+func (b *eventBus) publish(event *defs.GameEvent) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	for _, events := range b.subscribers {
+		select {
+		case events <- event:
+		default:
+			fmt.Println("event subscriber is falling behind, dropping event", event.Code)
+		}
+	}
+}
+
+// console lines come as "[12:34:56] [Server thread/INFO]: message" from vanilla, or "[12:34:56 INFO]: message" from
+// paper and its forks
+var (
+	vanillaLineRegexp = regexp.MustCompile(`^\[\d{2}:\d{2}:\d{2}\] \[([^\]]*)/([A-Z]+)\]: (.*)$`)
+	paperLineRegexp   = regexp.MustCompile(`^\[\d{2}:\d{2}:\d{2} ([A-Z]+)\]: (.*)$`)
+)
+
+// playerNamePattern matches the names minecraft allows, with the leading dot geyser gives bedrock players. nothing
+// else can pass for a player, so "/say joined the game" (logged as "[Steve] joined the game") isn't taken for a join
+const playerNamePattern = `(\.?\w{1,16})`
+
+var (
+	uuidRegexp        = regexp.MustCompile(`^UUID of player ` + playerNamePattern + ` is ([0-9a-f-]+)$`)
+	loggedInRegexp    = regexp.MustCompile(`^` + playerNamePattern + `\[/([^\]]+)\] logged in with entity id`)
+	joinedRegexp      = regexp.MustCompile(`^` + playerNamePattern + ` joined the game$`)
+	lostRegexp        = regexp.MustCompile(`^` + playerNamePattern + ` lost connection: (.*)$`)
+	leftRegexp        = regexp.MustCompile(`^` + playerNamePattern + ` left the game$`)
+	chatRegexp        = regexp.MustCompile(`^(?:\[Not Secure\] )?<` + playerNamePattern + `> (.*)$`)
+	advancementRegexp = regexp.MustCompile(`^` + playerNamePattern + ` has (made the advancement|completed the challenge|reached the goal) \[(.*)\]$`)
+	lagRegexp         = regexp.MustCompile(`^Can't keep up! Is the server overloaded\? Running (\d+)ms or (\d+) ticks behind`)
+	startingRegexp    = regexp.MustCompile(`^Starting minecraft server version (.*)$`)
+	preparingRegexp   = regexp.MustCompile(`^Preparing spawn area: (\d+)%$`)
+)
+
+var advancementKinds = map[string]string{
+	"made the advancement":    "advancement",
+	"completed the challenge": "challenge",
+	"reached the goal":        "goal",
+}
+
+// deathPhrases are how vanilla death messages carry on after the player's name
+var deathPhrases = []string{
+	"was ", "drowned", "died", "fell ", "burned to death", "blew up", "hit the ground too hard", "suffocated",
+	"starved to death", "froze to death", "experienced kinetic energy", "went up in flames", "walked into",
+	"tried to swim in lava", "withered away", "went off with a bang", "discovered the floor was lava",
+	"didn't want to live", "left the confines of this world",
+}
+
+// eventParser turns one server's console lines into game events. it keeps track of who's online so it can tie the
+// lines a join is spread over together, and tell deaths from other lines that start with a name
+type eventParser struct {
+	world   string
+	uuids   map[string]string
+	ips     map[string]string
+	reasons map[string]string
+	online  map[string]bool
+}
+
+func newEventParser(world string) *eventParser {
+	return &eventParser{
+		world:   world,
+		uuids:   make(map[string]string),
+		ips:     make(map[string]string),
+		reasons: make(map[string]string),
+		online:  make(map[string]bool),
+	}
+}
+
+// parse reads a console line, returning the event it describes or nil if it isn't one
+func (p *eventParser) parse(line string) *defs.GameEvent {
+	var thread, message string
+	if parts := vanillaLineRegexp.FindStringSubmatch(line); parts != nil {
+		thread, message = parts[1], parts[3]
+	} else if parts := paperLineRegexp.FindStringSubmatch(line); parts != nil {
+		thread, message = "Server thread", parts[2]
+	} else {
+		return nil
+	}
+
+	event := func(code defs.GameEventCode, player string, args map[string]string) *defs.GameEvent {
+		if args == nil {
+			args = map[string]string{}
+		}
+		return &defs.GameEvent{Code: code, World: p.world, Player: player, Time: time.Now(), Args: args}
+	}
+
+	if parts := uuidRegexp.FindStringSubmatch(message); parts != nil {
+		p.uuids[parts[1]] = parts[2]
+		return nil
+	}
+	if parts := loggedInRegexp.FindStringSubmatch(message); parts != nil {
+		p.ips[parts[1]] = strings.Split(parts[2], ":")[0]
+		return nil
+	}
+	if parts := chatRegexp.FindStringSubmatch(message); parts != nil {
+		return event(defs.Chat, parts[1], map[string]string{"message": parts[2]})
+	}
+	if parts := startingRegexp.FindStringSubmatch(message); parts != nil {
+		return event(defs.StartupProgress, "", map[string]string{"stage": "starting", "version": parts[1]})
+	}
+	if parts := preparingRegexp.FindStringSubmatch(message); parts != nil {
+		return event(defs.StartupProgress, "", map[string]string{"stage": "preparing", "percent": parts[1]})
+	}
+	if thread != "Server thread" {
+		// player events come from the main thread, which keeps players from faking them with odd names
+		return nil
+	}
+
+	if parts := joinedRegexp.FindStringSubmatch(message); parts != nil {
+		player := parts[1]
+		p.online[player] = true
+		return event(defs.PlayerJoined, player, map[string]string{
+			"uuid":   p.uuids[player],
+			"ip":     p.ips[player],
+			"online": strconv.Itoa(len(p.online)),
+		})
+	}
+	if parts := lostRegexp.FindStringSubmatch(message); parts != nil {
+		// the reason shows up here, and the leave itself on the next line
+		p.reasons[parts[1]] = parts[2]
+		return nil
+	}
+	if parts := leftRegexp.FindStringSubmatch(message); parts != nil {
+		player := parts[1]
+		reason := p.reasons[player]
+		delete(p.online, player)
+		delete(p.uuids, player)
+		delete(p.ips, player)
+		delete(p.reasons, player)
+		return event(defs.PlayerLeft, player, map[string]string{"reason": reason, "online": strconv.Itoa(len(p.online))})
+	}
+	if parts := advancementRegexp.FindStringSubmatch(message); parts != nil {
+		return event(defs.Advancement, parts[1], map[string]string{"advancement": parts[3], "kind": advancementKinds[parts[2]]})
+	}
+	if parts := lagRegexp.FindStringSubmatch(message); parts != nil {
+		return event(defs.LagWarning, "", map[string]string{"behind": parts[1], "ticks": parts[2]})
+	}
+	if message == "Saved the game" || strings.HasSuffix(message, "All dimensions are saved") {
+		return event(defs.WorldSaved, "", nil)
+	}
+	if startup, ok := parseDoneLine(message); ok {
+		return event(defs.StartupProgress, "", map[string]string{"stage": "done", "startup": startup})
+	}
+
+	if player, ok := p.deathOf(message); ok {
+		return event(defs.Death, player, map[string]string{"message": message})
+	}
+	return nil
+}
+
+// deathOf checks whether a line is the death message of a player who's online
+func (p *eventParser) deathOf(message string) (string, bool) {
+	separator := strings.Index(message, " ")
+	if separator < 0 {
+		return "", false
+	}
+	player, rest := message[:separator], message[separator+1:]
+	if !p.online[player] {
+		return "", false
+	}
+	for _, phrase := range deathPhrases {
+		if strings.HasPrefix(rest, phrase) {
+			return player, true
+		}
+	}
+	return "", false
+}
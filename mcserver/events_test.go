@@ -0,0 +1,169 @@
+package mcserver
+
+import (
+	"testing"
+
+	"github.com/matthewdavidrodgers/dbot-mk2/defs"
+)
+
+func TestEventParser(t *testing.T) {
+	type wantEvent struct {
+		code   defs.GameEventCode
+		player string
+		args   map[string]string
+	}
+
+	cases := []struct {
+		name  string
+		lines []string
+		want  []wantEvent
+	}{
+		{
+			name: "vanilla join",
+			lines: []string{
+				"[18:02:11] [User Authenticator #1/INFO]: UUID of player Steve is 069a79f4-44e9-4726-a5be-fca90e38aaf5",
+				"[18:02:11] [Server thread/INFO]: Steve[/192.168.1.20:51234] logged in with entity id 312 at (8.5, 64.0, -3.5)",
+				"[18:02:11] [Server thread/INFO]: Steve joined the game",
+			},
+			want: []wantEvent{{defs.PlayerJoined, "Steve", map[string]string{
+				"uuid":   "069a79f4-44e9-4726-a5be-fca90e38aaf5",
+				"ip":     "192.168.1.20",
+				"online": "1",
+			}}},
+		},
+		{
+			name: "paper join",
+			lines: []string{
+				"[18:02:11 INFO]: UUID of player Alex_2 is ec561538-f3fd-461d-aff5-086b22154bce",
+				"[18:02:11 INFO]: Alex_2[/10.0.0.5:40000] logged in with entity id 97 at ([world]0.5, 70.0, 0.5)",
+				"[18:02:11 INFO]: Alex_2 joined the game",
+			},
+			want: []wantEvent{{defs.PlayerJoined, "Alex_2", map[string]string{
+				"uuid":   "ec561538-f3fd-461d-aff5-086b22154bce",
+				"ip":     "10.0.0.5",
+				"online": "1",
+			}}},
+		},
+		{
+			name: "lost connection then left",
+			lines: []string{
+				"[18:02:11] [Server thread/INFO]: Steve joined the game",
+				"[18:02:12] [Server thread/INFO]: Alex joined the game",
+				"[18:30:40] [Server thread/INFO]: Steve lost connection: Disconnected",
+				"[18:30:40] [Server thread/INFO]: Steve left the game",
+			},
+			want: []wantEvent{
+				{defs.PlayerJoined, "Steve", map[string]string{"online": "1"}},
+				{defs.PlayerJoined, "Alex", map[string]string{"online": "2"}},
+				{defs.PlayerLeft, "Steve", map[string]string{"reason": "Disconnected", "online": "1"}},
+			},
+		},
+		{
+			name: "chat",
+			lines: []string{
+				"[18:05:00] [Server thread/INFO]: <Steve> anyone got iron?",
+				"[18:05:01] [Server thread/INFO]: [Not Secure] <Alex> Steve joined the game",
+				"[18:05:02 INFO]: <Steve> Alex was slain by Zombie",
+			},
+			want: []wantEvent{
+				{defs.Chat, "Steve", map[string]string{"message": "anyone got iron?"}},
+				{defs.Chat, "Alex", map[string]string{"message": "Steve joined the game"}},
+				{defs.Chat, "Steve", map[string]string{"message": "Alex was slain by Zombie"}},
+			},
+		},
+		{
+			name: "death of an online player",
+			lines: []string{
+				"[18:02:11] [Server thread/INFO]: Steve joined the game",
+				"[18:10:00] [Server thread/INFO]: Steve was slain by Zombie",
+				"[18:11:00] [Server thread/INFO]: Steve fell from a high place",
+			},
+			want: []wantEvent{
+				{defs.PlayerJoined, "Steve", nil},
+				{defs.Death, "Steve", map[string]string{"message": "Steve was slain by Zombie"}},
+				{defs.Death, "Steve", map[string]string{"message": "Steve fell from a high place"}},
+			},
+		},
+		{
+			name: "death of an offline name",
+			lines: []string{
+				"[18:02:11] [Server thread/INFO]: Steve joined the game",
+				"[18:10:00] [Server thread/INFO]: Notch was slain by Zombie",
+				"[18:30:40] [Server thread/INFO]: Steve left the game",
+				"[18:31:00] [Server thread/INFO]: Steve drowned",
+			},
+			want: []wantEvent{
+				{defs.PlayerJoined, "Steve", nil},
+				{defs.PlayerLeft, "Steve", map[string]string{"online": "0"}},
+			},
+		},
+		{
+			name: "say and me spoofs",
+			lines: []string{
+				"[18:02:11] [Server thread/INFO]: Steve joined the game",
+				"[18:03:00] [Server thread/INFO]: [Steve] joined the game",
+				"[18:03:01] [Server thread/INFO]: [Steve] Notch joined the game",
+				"[18:03:02] [Server thread/INFO]: [Server] Herobrine joined the game",
+				"[18:03:03] [Server thread/INFO]: * Steve joined the game",
+				"[18:03:04] [Server thread/INFO]: [Steve] was slain by Zombie",
+				"[18:03:05] [Server thread/INFO]: * Steve was slain by Zombie",
+				"[18:03:06] [Server thread/INFO]: [Steve] left the game",
+				"[18:03:07 INFO]: [Steve] joined the game",
+				"[18:03:08 INFO]: * Steve left the game",
+			},
+			want: []wantEvent{{defs.PlayerJoined, "Steve", map[string]string{"online": "1"}}},
+		},
+		{
+			name: "player events off the main thread",
+			lines: []string{
+				"[18:02:11] [Async Chat Thread - #0/INFO]: Steve joined the game",
+				"[18:02:12] [Netty Epoll Server IO #2/INFO]: Steve left the game",
+			},
+			want: []wantEvent{},
+		},
+		{
+			name: "advancement and server events",
+			lines: []string{
+				"[18:20:00] [Server thread/INFO]: Steve has made the advancement [Stone Age]",
+				"[18:21:00] [Server thread/WARN]: Can't keep up! Is the server overloaded? Running 2034ms or 40 ticks behind",
+				"[18:22:00] [Server thread/INFO]: Saved the game",
+				"not a console line at all",
+			},
+			want: []wantEvent{
+				{defs.Advancement, "Steve", map[string]string{"advancement": "Stone Age", "kind": "advancement"}},
+				{defs.LagWarning, "", map[string]string{"behind": "2034", "ticks": "40"}},
+				{defs.WorldSaved, "", nil},
+			},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			p := newEventParser("my-world")
+			got := make([]*defs.GameEvent, 0)
+			for _, line := range c.lines {
+				if event := p.parse(line); event != nil {
+					got = append(got, event)
+				}
+			}
+
+			if len(got) != len(c.want) {
+				for _, event := range got {
+					t.Logf("got %d %q %v", event.Code, event.Player, event.Args)
+				}
+				t.Fatalf("got %d events, want %d", len(got), len(c.want))
+			}
+			for i, want := range c.want {
+				event := got[i]
+				if event.Code != want.code || event.Player != want.player || event.World != "my-world" {
+					t.Errorf("event %d: got %d %q in %q, want %d %q", i, event.Code, event.Player, event.World, want.code, want.player)
+				}
+				for key, value := range want.args {
+					if event.Args[key] != value {
+						t.Errorf("event %d: %s is %q, want %q", i, key, event.Args[key], value)
+					}
+				}
+			}
+		})
+	}
+}
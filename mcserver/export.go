@@ -0,0 +1,113 @@
+package mcserver
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/matthewdavidrodgers/dbot-mk2/defs"
+	"github.com/matthewdavidrodgers/dbot-mk2/utils"
+)
+
+// exportsDirName is where exports are packaged before they're uploaded to discord
+const exportsDirName = "bb-exports"
+
+// defaultUploadLimit is the biggest file discord accepts from a bot on a server without boosts, unless
+// BB_UPLOAD_LIMIT_MB says otherwise
+const defaultUploadLimit = 8 << 20
+
+// maxExportParts is the most parts an export can be split into before it's not worth the channel spam
+const maxExportParts = 20
+
+// the ways an export can be packaged, from best to worst
+const (
+	fullExport      = "full"
+	overworldExport = "overworld"
+	partsExport     = "parts"
+)
+
+// netherAndEnd are the dimension directories inside a vanilla level, left out of an overworld-only export
+var netherAndEnd = []string{"DIM-1", "DIM1"}
+
+// exportWorld zips a world's level so it can be posted to discord. if the zip is bigger than uploadLimit, the
+// overworld alone is tried next (unless parts is set), and failing that the zip is split into numbered parts.
+// console is the running server's console, or nil if the world isn't running
+func exportWorld(notify chan<- *defs.ServerResponseOp, world string, console func(command string) (string, error), uploadLimit int64, parts bool) {
+	fail := func(err error) {
+		fmt.Println("export of", world, "failed:", err)
+		notify <- &defs.ServerResponseOp{
+			Code: defs.ExportFailure,
+			Args: map[string]string{"world": world, "error": err.Error()},
+		}
+	}
+
+	pwd, err := os.Getwd()
+	if err != nil {
+		fail(err)
+		return
+	}
+	level, err := levelDir(filepath.Join(pwd, "bb-worlds", world))
+	if err != nil {
+		fail(err)
+		return
+	}
+
+	exportDir := filepath.Join(pwd, exportsDirName, world+"-"+time.Now().Format(backupIDFormat))
+	err = os.MkdirAll(exportDir, 0755)
+	if err != nil {
+		fail(err)
+		return
+	}
+
+	kind := fullExport
+	destination := filepath.Join(exportDir, world+".zip")
+	err = withSavingPaused(console, func() error {
+		err := utils.ZipDir(level, destination, nil)
+		if err != nil || parts || fileSize(destination) <= uploadLimit {
+			return err
+		}
+		kind = overworldExport
+		destination = filepath.Join(exportDir, world+"-overworld.zip")
+		return utils.ZipDir(level, destination, netherAndEnd)
+	})
+	if err != nil {
+		os.RemoveAll(exportDir)
+		fail(err)
+		return
+	}
+
+	files := []string{destination}
+	if fileSize(destination) > uploadLimit {
+		kind = partsExport
+		destination = filepath.Join(exportDir, world+".zip")
+		files, err = utils.SplitFile(destination, uploadLimit)
+		if err == nil && len(files) > maxExportParts {
+			err = fmt.Errorf("it would take %d uploads, which is more than the %d i'm willing to post", len(files), maxExportParts)
+		}
+		if err != nil {
+			os.RemoveAll(exportDir)
+			fail(err)
+			return
+		}
+	}
+
+	notify <- &defs.ServerResponseOp{
+		Code: defs.ExportSuccess,
+		Args: map[string]string{
+			"world": world,
+			"kind":  kind,
+			"dir":   exportDir,
+			"files": strings.Join(files, "\n"),
+		},
+	}
+}
+
+func fileSize(path string) int64 {
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0
+	}
+	return info.Size()
+}
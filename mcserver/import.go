@@ -0,0 +1,151 @@
+package mcserver
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/matthewdavidrodgers/dbot-mk2/defs"
+	"github.com/matthewdavidrodgers/dbot-mk2/utils"
+)
+
+// importsDirName is where imported worlds are unpacked before they're moved into bb-worlds
+const importsDirName = "bb-imports"
+
+// limits on imported worlds, so a bad upload can't fill the disk
+const (
+	maxImportDownloadSize  = 500 << 20
+	maxImportExtractedSize = 4 << 30
+)
+
+// importDownloadTimeout is how long an upload has to finish downloading, so a stalled one doesn't hold its name forever
+const importDownloadTimeout = 10 * time.Minute
+
+// importWorld downloads a zipped world, unpacks it, and sets it up as a new world the way createWorld does
+func importWorld(notify chan<- *defs.ServerResponseOp, name string, mode string, jar string, url string) {
+	args := map[string]string{"name": name}
+	fail := func(err error) {
+		fmt.Println("import of", name, "failed:", err)
+		args["error"] = err.Error()
+		notify <- &defs.ServerResponseOp{Code: defs.ImportFailure, Args: args}
+	}
+
+	pwd, err := os.Getwd()
+	if err != nil {
+		fail(err)
+		return
+	}
+	stagingDir := filepath.Join(pwd, importsDirName, name)
+	err = os.MkdirAll(stagingDir, 0755)
+	if err != nil {
+		fail(err)
+		return
+	}
+	defer os.RemoveAll(stagingDir)
+
+	zipFile := stagingDir + ".zip"
+	err = download(url, zipFile, maxImportDownloadSize)
+	if err != nil {
+		fail(err)
+		return
+	}
+	defer os.Remove(zipFile)
+
+	err = utils.ExtractZip(zipFile, stagingDir, maxImportExtractedSize)
+	if err == utils.ErrArchiveTooLarge {
+		err = fmt.Errorf("the world unpacks to more than %s", formatBytes(maxImportExtractedSize))
+	}
+	if err != nil {
+		fail(err)
+		return
+	}
+
+	levelDir, err := findLevelDir(stagingDir)
+	if err != nil {
+		fail(err)
+		return
+	}
+
+	worldDir := filepath.Join(pwd, "bb-worlds", name)
+	err = os.Mkdir(worldDir, 0755)
+	if err != nil {
+		fail(err)
+		return
+	}
+	// the server looks for its level in "world" unless server.properties says otherwise
+	err = os.Rename(levelDir, filepath.Join(worldDir, "world"))
+	if err == nil {
+		err = initWorld(name, jar, map[string]string{"gamemode": mode})
+	}
+	if err != nil {
+		os.RemoveAll(worldDir)
+		fail(err)
+		return
+	}
+
+	notify <- &defs.ServerResponseOp{Code: defs.ImportSuccess, Args: args}
+}
+
+// download saves the file at a url, failing if it's bigger than maxSize bytes
+func download(url string, destination string, maxSize int64) error {
+	client := &http.Client{Timeout: importDownloadTimeout}
+	resp, err := client.Get(url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("download failed with status %s", resp.Status)
+	}
+	if resp.ContentLength > maxSize {
+		return fmt.Errorf("the file is bigger than %s", formatBytes(maxSize))
+	}
+
+	out, err := os.Create(destination)
+	if err != nil {
+		return err
+	}
+	written, err := io.Copy(out, io.LimitReader(resp.Body, maxSize+1))
+	closeErr := out.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err == nil && written > maxSize {
+		err = fmt.Errorf("the file is bigger than %s", formatBytes(maxSize))
+	}
+	return err
+}
+
+// findLevelDir finds the directory holding level.dat in an unpacked world, which zips often nest a few levels deep.
+// the shallowest one wins
+func findLevelDir(dir string) (string, error) {
+	found := make([]string, 0)
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() && info.Name() == "__MACOSX" {
+			return filepath.SkipDir
+		}
+		if !info.IsDir() && info.Name() == "level.dat" {
+			found = append(found, filepath.Dir(path))
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	if len(found) == 0 {
+		return "", errors.New("there's no level.dat in the zip. is it really a world?")
+	}
+	sort.Slice(found, func(i, j int) bool {
+		return strings.Count(found[i], string(filepath.Separator)) < strings.Count(found[j], string(filepath.Separator))
+	})
+	return found[0], nil
+}
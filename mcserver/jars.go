@@ -0,0 +1,210 @@
+package mcserver
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/matthewdavidrodgers/dbot-mk2/utils"
+)
+
+// jarsDirName is the registry of server jars worlds can be pinned to, each stored as <name>.jar
+const jarsDirName = "bb-jars"
+
+// legacyJarName is the single server.jar in the working directory, used by worlds that aren't pinned to a jar
+const legacyJarName = "server.jar"
+
+type serverJar struct {
+	name    string
+	path    string
+	version string
+}
+
+// jarVersionInfo is the version.json vanilla (and most forks) embed in their server jars
+type jarVersionInfo struct {
+	ID              string `json:"id"`
+	Name            string `json:"name"`
+	WorldVersion    int    `json:"world_version"`
+	ProtocolVersion int    `json:"protocol_version"`
+}
+
+// getJars lists the jars in the registry, with the minecraft version each one embeds
+func getJars() ([]serverJar, error) {
+	pwd, err := os.Getwd()
+	if err != nil {
+		return nil, err
+	}
+
+	dir, err := ioutil.ReadDir(filepath.Join(pwd, jarsDirName))
+	if os.IsNotExist(err) {
+		return []serverJar{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	jars := make([]serverJar, 0)
+	for _, file := range dir {
+		if file.IsDir() || !strings.HasSuffix(file.Name(), ".jar") {
+			continue
+		}
+		path := filepath.Join(pwd, jarsDirName, file.Name())
+		version, err := jarVersion(path)
+		if err != nil {
+			version = "unknown"
+		}
+		jars = append(jars, serverJar{
+			name:    strings.TrimSuffix(file.Name(), ".jar"),
+			path:    path,
+			version: version,
+		})
+	}
+	return jars, nil
+}
+
+// jarVersion reads the minecraft version a server jar was built for from its embedded version.json
+func jarVersion(path string) (string, error) {
+	archive, err := zip.OpenReader(path)
+	if err != nil {
+		return "", err
+	}
+	defer archive.Close()
+
+	for _, file := range archive.File {
+		if file.Name != "version.json" {
+			continue
+		}
+		reader, err := file.Open()
+		if err != nil {
+			return "", err
+		}
+		defer reader.Close()
+
+		var info jarVersionInfo
+		err = json.NewDecoder(reader).Decode(&info)
+		if err != nil {
+			return "", err
+		}
+		if info.Name != "" {
+			return info.Name, nil
+		}
+		return info.ID, nil
+	}
+	return "", errors.New("jar has no version.json")
+}
+
+// jarPath finds the jar a world runs on: the registry jar it's pinned to, or the legacy server.jar if it isn't
+func jarPath(config *worldConfig) (string, error) {
+	pwd, err := os.Getwd()
+	if err != nil {
+		return "", err
+	}
+
+	path := filepath.Join(pwd, legacyJarName)
+	if config.Jar != "" {
+		path = filepath.Join(pwd, jarsDirName, config.Jar+".jar")
+	}
+	if _, err := os.Stat(path); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+func jarExists(name string) bool {
+	jars, _ := getJars()
+	for _, jar := range jars {
+		if jar.name == name {
+			return true
+		}
+	}
+	return false
+}
+
+var jarNameUnsafeRegexp = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// pinLegacyJarLock keeps worlds being created at the same time from copying server.jar over each other
+var pinLegacyJarLock sync.Mutex
+
+// pinLegacyJar copies the legacy server.jar into the registry, named after the minecraft version it embeds, so a new
+// world made without a version stays on that jar when server.jar is upgraded. a registry jar of that name with the
+// same contents is reused rather than copied again. it returns the name of the registry jar
+func pinLegacyJar() (string, error) {
+	pinLegacyJarLock.Lock()
+	defer pinLegacyJarLock.Unlock()
+
+	pwd, err := os.Getwd()
+	if err != nil {
+		return "", err
+	}
+	legacy := filepath.Join(pwd, legacyJarName)
+	version, err := jarVersion(legacy)
+	if err != nil {
+		version = "unknown"
+	}
+	base := "server-" + jarNameUnsafeRegexp.ReplaceAllString(version, "-")
+
+	err = os.MkdirAll(filepath.Join(pwd, jarsDirName), 0755)
+	if err != nil {
+		return "", err
+	}
+	for n := 1; ; n++ {
+		name := base
+		if n > 1 {
+			name = fmt.Sprintf("%s-%d", base, n)
+		}
+		path := filepath.Join(pwd, jarsDirName, name+".jar")
+
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			err = utils.CopyFile(legacy, path, 0644)
+			if err != nil {
+				os.Remove(path)
+				return "", err
+			}
+			return name, nil
+		}
+		same, err := sameContents(legacy, path)
+		if err != nil {
+			return "", err
+		}
+		if same {
+			return name, nil
+		}
+	}
+}
+
+func sameContents(a string, b string) (bool, error) {
+	digestA, err := fileDigest(a)
+	if err != nil {
+		return false, err
+	}
+	digestB, err := fileDigest(b)
+	if err != nil {
+		return false, err
+	}
+	return bytes.Equal(digestA, digestB), nil
+}
+
+func fileDigest(path string) ([]byte, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	_, err = io.Copy(hash, file)
+	if err != nil {
+		return nil, err
+	}
+	return hash.Sum(nil), nil
+}
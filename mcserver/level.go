@@ -0,0 +1,118 @@
+package mcserver
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/matthewdavidrodgers/dbot-mk2/nbt"
+	"github.com/matthewdavidrodgers/dbot-mk2/utils"
+)
+
+// levelDir finds the directory a world's server keeps its level in, which is "world" unless server.properties says
+// otherwise. server.properties can be edited by hand, so a level-name that points outside of the world directory is an
+// error
+func levelDir(worldDir string) (string, error) {
+	levelName, err := utils.GetNamedValueInTextFile(filepath.Join(worldDir, "server.properties"), "level-name")
+	if err != nil {
+		return "", err
+	}
+	if levelName == "" {
+		levelName = "world"
+	}
+	level := filepath.Join(worldDir, levelName)
+	rel, err := filepath.Rel(worldDir, level)
+	if err != nil {
+		return "", err
+	}
+	if rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("level-name %q is not a directory inside of the world", levelName)
+	}
+	return level, nil
+}
+
+// levelInfo is what level.dat has to say about a world
+type levelInfo struct {
+	seed        string
+	dataVersion int64
+	gameVersion string
+	dayTime     int64
+	spawn       string
+	difficulty  string
+	hardcore    bool
+	lastPlayed  time.Time
+}
+
+var difficultyNames = []string{"peaceful", "easy", "normal", "hard"}
+
+// readLevelInfo reads a world's level.dat
+func readLevelInfo(world string) (*levelInfo, error) {
+	pwd, err := os.Getwd()
+	if err != nil {
+		return nil, err
+	}
+	dir, err := levelDir(filepath.Join(pwd, "bb-worlds", world))
+	if err != nil {
+		return nil, err
+	}
+	root, err := nbt.ReadFile(filepath.Join(dir, "level.dat"))
+	if err != nil {
+		return nil, err
+	}
+	data := root.Compound("Data")
+	if data == nil {
+		return nil, fmt.Errorf("level.dat has no Data")
+	}
+
+	info := &levelInfo{}
+	// the seed moved into WorldGenSettings in 1.16
+	if seed, ok := data.Compound("WorldGenSettings").Int("seed"); ok {
+		info.seed = strconv.FormatInt(seed, 10)
+	} else if seed, ok := data.Int("RandomSeed"); ok {
+		info.seed = strconv.FormatInt(seed, 10)
+	}
+	info.dataVersion, _ = data.Int("DataVersion")
+	info.gameVersion = data.Compound("Version").String("Name")
+	info.dayTime, _ = data.Int("DayTime")
+
+	// newer versions keep the spawn point in a compound of its own
+	if pos := data.Compound("spawn").IntArray("pos"); len(pos) == 3 {
+		info.spawn = fmt.Sprintf("%d, %d, %d", pos[0], pos[1], pos[2])
+	} else {
+		x, okX := data.Int("SpawnX")
+		y, okY := data.Int("SpawnY")
+		z, okZ := data.Int("SpawnZ")
+		if okX && okY && okZ {
+			info.spawn = fmt.Sprintf("%d, %d, %d", x, y, z)
+		}
+	}
+
+	if settings := data.Compound("difficulty_settings"); settings != nil {
+		info.difficulty = settings.String("difficulty")
+		hardcore, _ := settings.Int("hardcore")
+		info.hardcore = hardcore != 0
+	} else {
+		if difficulty, ok := data.Int("Difficulty"); ok && difficulty >= 0 && int(difficulty) < len(difficultyNames) {
+			info.difficulty = difficultyNames[difficulty]
+		}
+		hardcore, _ := data.Int("hardcore")
+		info.hardcore = hardcore != 0
+	}
+
+	if lastPlayed, ok := data.Int("LastPlayed"); ok && lastPlayed > 0 {
+		info.lastPlayed = time.Unix(0, lastPlayed*int64(time.Millisecond))
+	}
+	return info, nil
+}
+
+// describeDayTime renders a world's day time, in ticks, as its in-game day and clock. ticks start at 6am
+func describeDayTime(dayTime int64) string {
+	day := dayTime/24000 + 1
+	ticks := dayTime % 24000
+	hour := (ticks/1000 + 6) % 24
+	minute := ticks % 1000 * 60 / 1000
+	return fmt.Sprintf("day %d, %02d:%02d", day, hour, minute)
+}
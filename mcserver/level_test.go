@@ -0,0 +1,143 @@
+package mcserver
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// nbtWriter writes just enough nbt by hand to make a level.dat
+type nbtWriter struct {
+	bytes.Buffer
+}
+
+func (w *nbtWriter) tag(tagType byte, name string) *nbtWriter {
+	w.WriteByte(tagType)
+	binary.Write(&w.Buffer, binary.BigEndian, uint16(len(name)))
+	w.WriteString(name)
+	return w
+}
+
+func (w *nbtWriter) num(v interface{}) *nbtWriter {
+	binary.Write(&w.Buffer, binary.BigEndian, v)
+	return w
+}
+
+func (w *nbtWriter) str(s string) *nbtWriter {
+	w.num(uint16(len(s)))
+	w.WriteString(s)
+	return w
+}
+
+func (w *nbtWriter) end() *nbtWriter {
+	w.WriteByte(0)
+	return w
+}
+
+// the nbt tag types level.dat uses
+const (
+	nbtByte     = 1
+	nbtInt      = 3
+	nbtLong     = 4
+	nbtString   = 8
+	nbtCompound = 10
+	nbtIntArray = 11
+)
+
+func TestReadLevelInfo(t *testing.T) {
+	lastPlayed := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	// 1.15: the seed and spawn sit right in Data
+	old := &nbtWriter{}
+	old.tag(nbtCompound, "").tag(nbtCompound, "Data")
+	old.tag(nbtLong, "RandomSeed").num(int64(-4172144997902289642))
+	old.tag(nbtInt, "DataVersion").num(int32(2230))
+	old.tag(nbtCompound, "Version").tag(nbtString, "Name").str("1.15.2").end()
+	old.tag(nbtLong, "DayTime").num(int64(30000))
+	old.tag(nbtInt, "SpawnX").num(int32(-120))
+	old.tag(nbtInt, "SpawnY").num(int32(64))
+	old.tag(nbtInt, "SpawnZ").num(int32(256))
+	old.tag(nbtByte, "Difficulty").num(int8(2))
+	old.tag(nbtByte, "hardcore").num(int8(0))
+	old.tag(nbtLong, "LastPlayed").num(lastPlayed.UnixNano() / int64(time.Millisecond))
+	old.end().end()
+
+	// 1.21: the seed moved into WorldGenSettings, and the spawn and difficulty into compounds of their own
+	current := &nbtWriter{}
+	current.tag(nbtCompound, "").tag(nbtCompound, "Data")
+	current.tag(nbtCompound, "WorldGenSettings").tag(nbtLong, "seed").num(int64(1234)).end()
+	current.tag(nbtInt, "DataVersion").num(int32(4189))
+	current.tag(nbtCompound, "Version").tag(nbtString, "Name").str("1.21.4").end()
+	current.tag(nbtLong, "DayTime").num(int64(6000))
+	current.tag(nbtCompound, "spawn").tag(nbtIntArray, "pos").num(int32(3)).num([]int32{8, 70, -8}).end()
+	current.tag(nbtCompound, "difficulty_settings").tag(nbtString, "difficulty").str("hard").tag(nbtByte, "hardcore").num(int8(1)).end()
+	current.end().end()
+
+	cases := []struct {
+		name  string
+		level []byte
+		want  levelInfo
+	}{
+		{"pre-1.16", old.Bytes(), levelInfo{
+			seed:        "-4172144997902289642",
+			dataVersion: 2230,
+			gameVersion: "1.15.2",
+			dayTime:     30000,
+			spawn:       "-120, 64, 256",
+			difficulty:  "normal",
+			lastPlayed:  lastPlayed,
+		}},
+		{"world gen settings and spawn", current.Bytes(), levelInfo{
+			seed:        "1234",
+			dataVersion: 4189,
+			gameVersion: "1.21.4",
+			dayTime:     6000,
+			spawn:       "8, 70, -8",
+			difficulty:  "hard",
+			hardcore:    true,
+		}},
+	}
+
+	pwd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(pwd)
+	err = os.Chdir(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			level := filepath.Join("bb-worlds", "w", "world")
+			err := os.MkdirAll(level, 0755)
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = ioutil.WriteFile(filepath.Join("bb-worlds", "w", "server.properties"), []byte("level-name=world\n"), 0644)
+			if err == nil {
+				err = ioutil.WriteFile(filepath.Join(level, "level.dat"), c.level, 0644)
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			info, err := readLevelInfo("w")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !info.lastPlayed.Equal(c.want.lastPlayed) {
+				t.Errorf("last played %v, want %v", info.lastPlayed, c.want.lastPlayed)
+			}
+			info.lastPlayed = c.want.lastPlayed
+			if *info != c.want {
+				t.Errorf("got %+v\nwant %+v", *info, c.want)
+			}
+		})
+	}
+}
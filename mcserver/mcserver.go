@@ -2,10 +2,14 @@ package mcserver
 
 import (
 	"fmt"
+	"io"
 	"io/ioutil"
+	"net"
 	"os"
-	"os/exec"
 	"path/filepath"
+	"strconv"
+	"sync"
+	"syscall"
 	"time"
 
 	"github.com/matthewdavidrodgers/dbot-mk2/defs"
@@ -13,12 +17,36 @@ import (
 )
 
 type server struct {
-	startedOn time.Time
-	worldName string
-	stop      func()
-	kill      func()
+	state          serverStateCode
+	startedOn      time.Time
+	worldName      string
+	port           int
+	pid            int
+	autoRestarting bool
+	exited         <-chan struct{}
+	console        func(command string) (string, error)
+	stop           func() error
+	kill           func()
+
+	// listen calls fn with every line the server writes to its console, until cancel is called
+	listen func(fn func(line string)) (cancel func())
+}
+
+// active reports whether the server's process is (or may still be) alive, holding on to its world and port
+func (s *server) active() bool {
+	return s.state == starting || s.state == running || s.state == stopping || s.state == stoppingForcefully
 }
 
+const (
+	// each server's rcon port is its game port plus this offset, so that the default game port 25565 gets the
+	// default rcon port 25575
+	rconPortOffset = 10
+
+	// if the server hasn't logged that it's done starting by now, fall back to pinging it
+	readinessPingDelay    = 60 * time.Second
+	readinessPingInterval = 10 * time.Second
+)
+
 type serverStateCode int
 
 const (
@@ -27,12 +55,55 @@ const (
 	running
 	stopping
 	crashed
+	stoppingForcefully
+	stoppedForcefully
 )
 
+// defaultStopTimeout is how long a server gets to save and exit after being asked to stop, unless BB_STOP_TIMEOUT
+// says otherwise
+const defaultStopTimeout = 2 * time.Minute
+
 type manager struct {
-	state           serverStateCode
-	server          *server
+	// servers holds the last server run for each world, keyed by world name. entries stick around after the
+	// server exits so its outcome can still be reported
+	servers         map[string]*server
+	ports           []int
 	serverResponses chan *defs.ServerResponseOp
+	stopTimeout     time.Duration
+
+	// crash history and restart attempts per world, for auto-restarts
+	crashes         map[string][]time.Time
+	restartAttempts map[string]int
+
+	// busy holds the worlds with something in progress on their files (a backup, a restore) and what it is
+	busy map[string]string
+
+	// deletes waiting to be confirmed, keyed by world name
+	pendingDeletes map[string]pendingDelete
+
+	// the biggest file that can be uploaded to discord
+	uploadLimit int64
+
+	// reply holds anything besides its text for the message the current action returns, like files to upload or the
+	// channel to send it to
+	reply *defs.DiscordResponse
+
+	// discord is where messages that don't answer an action go, and tails are the consoles being streamed there,
+	// keyed by world name
+	discord     chan<- *defs.DiscordResponse
+	tails       map[string]*logTail
+	tailChannel string
+
+	// events carries what happens in the running games to anything that wants to know
+	events *eventBus
+
+	// who wants to hear about players joining and leaving
+	notify *notifySettings
+
+	// when backups are made and how long they're kept
+	backupInterval time.Duration
+	backupOnStop   bool
+	retention      retentionPolicy
 }
 
 type bbWorld struct {
@@ -68,7 +139,22 @@ func getWorlds() ([]bbWorld, error) {
 	return worlds, nil
 }
 
-func createWorld(notify chan<- *defs.ServerResponseOp, name string, mode string) {
+// cleanScratchDir clears out a working directory of anything left behind by work that was interrupted
+func cleanScratchDir(name string) {
+	pwd, err := os.Getwd()
+	if err != nil {
+		return
+	}
+	dir, err := ioutil.ReadDir(filepath.Join(pwd, name))
+	if err != nil {
+		return
+	}
+	for _, file := range dir {
+		os.RemoveAll(filepath.Join(pwd, name, file.Name()))
+	}
+}
+
+func createWorld(notify chan<- *defs.ServerResponseOp, name string, jar string, properties map[string]string) {
 	pwd, err := os.Getwd()
 	if err != nil {
 		notify <- &defs.ServerResponseOp{Code: defs.CreateWorldFailure}
@@ -81,105 +167,402 @@ func createWorld(notify chan<- *defs.ServerResponseOp, name string, mode string)
 		return
 	}
 
-	jarFileLocation := filepath.Join(pwd, "server.jar")
+	err = initWorld(name, jar, properties)
+	if err != nil {
+		fmt.Println(err)
+		notify <- &defs.ServerResponseOp{Code: defs.CreateWorldFailure}
+		return
+	}
+
+	op := defs.ServerResponseOp{Code: defs.CreateWorldSuccess}
+	args := map[string]string{"name": name}
+	op.Args = args
+	notify <- &op
+}
 
-	createCmd := exec.Command("java", "-Xmx1024M", "-Xms512M", "-jar", jarFileLocation, "--nogui", "--initSettings")
+// initWorld sets up a world directory for the bot: its settings, and a server.properties and eula.txt generated by
+// the server itself, with the given properties written over the defaults. nothing is generated until the world's
+// first start, so the properties shape the level. a level already in the directory is left as it is
+func initWorld(name string, jar string, properties map[string]string) error {
+	pwd, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(pwd, "bb-worlds", name)
+
+	if jar == "" {
+		// new worlds are always pinned, so upgrading server.jar doesn't upgrade them along with it
+		jar, err = pinLegacyJar()
+		if err != nil {
+			return err
+		}
+	}
+	config := &worldConfig{Jar: jar}
+	err = writeWorldConfig(name, config)
+	if err != nil {
+		return err
+	}
+	jarFileLocation, err := jarPath(config)
+	if err != nil {
+		return err
+	}
+
+	createCmd := config.launchCommand(jarFileLocation, "--nogui", "--initSettings")
 	createCmd.Dir = path
 
 	output, err := createCmd.CombinedOutput()
 	if err != nil {
 		fmt.Println(string(output))
-		notify <- &defs.ServerResponseOp{Code: defs.CreateWorldFailure}
-		return
+		return err
 	}
 
-	utils.ReplaceNamedValueInTextFile(filepath.Join(path, "server.properties"), "gamemode", mode)
+	for key, value := range properties {
+		err = utils.SetNamedValueInTextFile(filepath.Join(path, "server.properties"), key, value)
+		if err != nil {
+			return err
+		}
+	}
 	utils.ReplaceNamedValueInTextFile(filepath.Join(path, "eula.txt"), "eula", "true")
+	return configureRcon(filepath.Join(path, "server.properties"))
+}
 
-	op := defs.ServerResponseOp{Code: defs.CreateWorldSuccess}
-	args := map[string]string{"name": name}
-	op.Args = args
-	notify <- &op
+// configureRcon enables rcon in a server.properties file, generating a new password for it
+func configureRcon(propertiesFile string) error {
+	password, err := generateRconPassword()
+	if err != nil {
+		return err
+	}
+	err = utils.SetNamedValueInTextFile(propertiesFile, "enable-rcon", "true")
+	if err != nil {
+		return err
+	}
+	return utils.SetNamedValueInTextFile(propertiesFile, "rcon.password", password)
+}
+
+// prepareServerProperties points a world's server.properties at the port it was assigned (and the matching rcon
+// port), configuring rcon first if the world was created before the bot managed it. it returns the rcon address
+// and password
+func prepareServerProperties(propertiesFile string, port int) (string, string, error) {
+	enabled, err := utils.GetNamedValueInTextFile(propertiesFile, "enable-rcon")
+	if err != nil {
+		return "", "", err
+	}
+	password, err := utils.GetNamedValueInTextFile(propertiesFile, "rcon.password")
+	if err != nil {
+		return "", "", err
+	}
+	if enabled != "true" || password == "" {
+		err = configureRcon(propertiesFile)
+		if err != nil {
+			return "", "", err
+		}
+		password, err = utils.GetNamedValueInTextFile(propertiesFile, "rcon.password")
+		if err != nil {
+			return "", "", err
+		}
+	}
+
+	rconPort := port + rconPortOffset
+	err = utils.SetNamedValueInTextFile(propertiesFile, "server-port", strconv.Itoa(port))
+	if err != nil {
+		return "", "", err
+	}
+	err = utils.SetNamedValueInTextFile(propertiesFile, "rcon.port", strconv.Itoa(rconPort))
+	if err != nil {
+		return "", "", err
+	}
+
+	return net.JoinHostPort("localhost", strconv.Itoa(rconPort)), password, nil
 }
 
-func startServer(notify chan<- *defs.ServerResponseOp, world string) *server {
-	serverCmd := exec.Command("java", "-Xmx1024M", "-Xms512M", "-jar", "../../server.jar", "--nogui")
+// serverProcess is a running server process, whether the bot launched it or adopted it after restarting
+type serverProcess struct {
+	pid       int
+	startedOn time.Time
+	// output is the server's console output. it must reach EOF once wait returns
+	output io.Reader
+	// wait blocks until the process exits
+	wait func()
+	kill func()
+}
+
+func startServer(notify chan<- *defs.ServerResponseOp, events *eventBus, world string, port int) (*server, error) {
+	config, err := readWorldConfig(world)
+	if err != nil {
+		return nil, err
+	}
+	jar, err := jarPath(config)
+	if err != nil {
+		return nil, err
+	}
+	serverCmd := config.launchCommand(jar, "--nogui")
 	pwd, err := os.Getwd()
-	utils.Check(err)
+	if err != nil {
+		return nil, err
+	}
 	serverCmd.Dir = filepath.Join(pwd, "bb-worlds", world)
+	// keep the server in its own process group, so it outlives the bot if the bot gets interrupted
+	serverCmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
 
-	logFile, err := os.OpenFile("bb-logs", os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0666)
-	utils.Check(err)
-
-	serverCmd.Stdout = logFile
-	serverCmd.Stderr = logFile
-	serverInputPipe, err := serverCmd.StdinPipe()
-	utils.Check(err)
+	propertiesFile := filepath.Join(serverCmd.Dir, "server.properties")
+	err = applyPendingProperties(world, config, propertiesFile)
+	if err != nil {
+		return nil, err
+	}
+	rconAddress, rconPassword, err := prepareServerProperties(propertiesFile, port)
+	if err != nil {
+		return nil, err
+	}
 
 	now := time.Now()
-	portPollSucceeded := make(chan bool)
-	abortPortPolling := make(chan bool)
+	logFile, err := openSessionLog(world, now)
+	if err != nil {
+		return nil, err
+	}
 
-	go func() {
-		logFile.WriteString("\n\n=== BEGIN BB SESSION " + now.String() + " ===\n\n\n")
-		serverCmd.Start()
-		serverCmd.Wait()
+	consoleReader, consoleWriter := io.Pipe()
+	serverCmd.Stdout = consoleWriter
+	serverCmd.Stderr = consoleWriter
 
+	err = serverCmd.Start()
+	if err != nil {
 		logFile.Close()
-		notify <- &defs.ServerResponseOp{Code: defs.Stopped}
-	}()
+		return nil, err
+	}
 
-	go func() {
-		for {
-			pingPortCmd := exec.Command("/bin/sh", "-c", "sudo lsof -i -P -n | grep 'TCP \\*:25565 (LISTEN)'")
-			resp, err := pingPortCmd.CombinedOutput()
+	process := serverProcess{
+		pid:       serverCmd.Process.Pid,
+		startedOn: now,
+		output:    consoleReader,
+		wait: func() {
+			serverCmd.Wait()
+			consoleWriter.Close()
+		},
+		kill: func() {
+			serverCmd.Process.Kill()
+		},
+	}
+	return superviseServer(notify, events, world, port, starting, rconAddress, rconPassword, logFile, process), nil
+}
 
-			bound := err == nil && len(resp) > 0
-			portPollSucceeded <- bound
-			if bound {
-				fmt.Println("polled for port - success!")
-				return
+// superviseServer watches over a server process: logging its console, publishing the game events in it, reporting
+// when it's ready (unless it already is) and when it exits, and giving the manager a way to talk to it
+func superviseServer(notify chan<- *defs.ServerResponseOp, events *eventBus, world string, port int, state serverStateCode, rconAddress string, rconPassword string, logFile *os.File, process serverProcess) *server {
+	var rcon *rconClient
+	var rconLock sync.Mutex
+	console := func(command string) (string, error) {
+		rconLock.Lock()
+		defer rconLock.Unlock()
+
+		if rcon == nil {
+			client, err := dialRcon(rconAddress, rconPassword)
+			if err != nil {
+				return "", err
 			}
-			fmt.Println("polled for port - failed")
-			time.Sleep(5 * time.Second)
+			rcon = client
+		}
+		reply, err := rcon.command(command)
+		if err != nil {
+			// drop the connection so the next command reconnects
+			rcon.close()
+			rcon = nil
+		}
+		return reply, err
+	}
 
+	var listenersLock sync.Mutex
+	listeners := make(map[int]func(line string))
+	nextListener := 0
+	listen := func(fn func(line string)) func() {
+		listenersLock.Lock()
+		defer listenersLock.Unlock()
+		id := nextListener
+		nextListener++
+		listeners[id] = fn
+		return func() {
+			listenersLock.Lock()
+			defer listenersLock.Unlock()
+			delete(listeners, id)
 		}
+	}
+
+	consoleDone := make(chan struct{})
+	pingDone := make(chan struct{})
+	exited := make(chan struct{})
+
+	var readyOnce sync.Once
+	markReady := func(reported string) {
+		readyOnce.Do(func() {
+			notify <- &defs.ServerResponseOp{
+				Code: defs.Started,
+				Args: map[string]string{
+					"world":    world,
+					"startup":  time.Since(process.startedOn).Round(time.Second).String(),
+					"reported": reported,
+				},
+			}
+		})
+	}
+	if state != starting {
+		// nothing to wait for
+		readyOnce.Do(func() {})
+	}
 
+	parser := newEventParser(world)
+	go func() {
+		defer close(consoleDone)
+		watchConsole(process.output, logFile, func(line string) {
+			if reported, ok := parseDoneLine(line); ok {
+				markReady(reported)
+			}
+			if event := parser.parse(line); event != nil {
+				events.publish(event)
+			}
+			listenersLock.Lock()
+			for _, fn := range listeners {
+				fn(line)
+			}
+			listenersLock.Unlock()
+		})
 	}()
 
 	go func() {
+		// in case the done line never shows up (say, a modded server with its own log format), a successful
+		// server list ping is just as good a sign that players can join
+		defer close(pingDone)
+		if state != starting {
+			return
+		}
+		wait := readinessPingDelay
 		for {
 			select {
-			case portWasBound := <-portPollSucceeded:
-				if portWasBound {
-					notify <- &defs.ServerResponseOp{Code: defs.Started}
-					return
-				}
-			case <-abortPortPolling:
+			case <-exited:
 				return
+			case <-time.After(wait):
 			}
+			if _, err := pingServer(net.JoinHostPort("localhost", strconv.Itoa(port)), 5*time.Second); err == nil {
+				markReady("")
+				return
+			}
+			wait = readinessPingInterval
+		}
+	}()
+
+	go func() {
+		process.wait()
+		close(exited)
+		<-consoleDone
+		<-pingDone
+
+		rconLock.Lock()
+		if rcon != nil {
+			rcon.close()
+			rcon = nil
 		}
+		rconLock.Unlock()
+
+		logFile.Close()
+		notify <- &defs.ServerResponseOp{Code: defs.Stopped, Args: map[string]string{"world": world}}
 	}()
 
 	return &server{
-		startedOn: now,
+		state:     state,
+		startedOn: process.startedOn,
 		worldName: world,
-		stop: func() {
-			serverInputPipe.Write([]byte("stop\n"))
-			serverInputPipe.Close()
-		},
-		kill: func() {
-			abortPortPolling <- true
-			serverCmd.Process.Kill()
+		port:      port,
+		pid:       process.pid,
+		exited:    exited,
+		console:   console,
+		stop: func() error {
+			_, err := console("stop")
+			if err == io.EOF {
+				// the server may hang up before replying
+				return nil
+			}
+			return err
 		},
+		kill:   process.kill,
+		listen: listen,
 	}
+}
 
+// watchStopDeadline reports back if a stopping server hasn't exited by the deadline
+func watchStopDeadline(notify chan<- *defs.ServerResponseOp, s *server, timeout time.Duration) {
+	select {
+	case <-s.exited:
+	case <-time.After(timeout):
+		notify <- &defs.ServerResponseOp{
+			Code: defs.StopTimedOut,
+			Args: map[string]string{"world": s.worldName, "startedOn": s.startedOn.String()},
+		}
+	}
 }
 
 // MakeServerManager listens to the serverRequest channel and performs ops against a mc server, sending string updates to the discordMessages channel
-func MakeServerManager(serverRequests <-chan *defs.ServerRequestOp, discordResponses chan<- string) {
+func MakeServerManager(serverRequests <-chan *defs.ServerRequestOp, discordResponses chan<- *defs.DiscordResponse) {
 	serverResponses := make(chan *defs.ServerResponseOp)
-	serverManager := &manager{state: idle, server: nil, serverResponses: serverResponses}
+	serverManager := &manager{
+		servers:         make(map[string]*server),
+		ports:           defaultPorts,
+		serverResponses: serverResponses,
+		stopTimeout:     defaultStopTimeout,
+		crashes:         make(map[string][]time.Time),
+		restartAttempts: make(map[string]int),
+		busy:            make(map[string]string),
+		pendingDeletes:  make(map[string]pendingDelete),
+		uploadLimit:     defaultUploadLimit,
+		discord:         discordResponses,
+		tails:           make(map[string]*logTail),
+		events:          newEventBus(),
+		notify:          readNotifySettings(),
+		tailChannel:     os.Getenv("BB_TAIL_CHANNEL"),
+		backupInterval:  defaultBackupInterval,
+		backupOnStop:    true,
+		retention:       retentionPolicy{hourly: defaultKeepHourly, daily: defaultKeepDaily, weekly: defaultKeepWeekly},
+	}
+
+	if stopTimeout, err := time.ParseDuration(os.Getenv("BB_STOP_TIMEOUT")); err == nil && stopTimeout > 0 {
+		serverManager.stopTimeout = stopTimeout
+	}
+	if portSpec := os.Getenv("BB_PORTS"); portSpec != "" {
+		ports, err := parsePortPool(portSpec)
+		utils.Check(err)
+		serverManager.ports = ports
+	}
+
+	if interval, err := time.ParseDuration(os.Getenv("BB_BACKUP_INTERVAL")); err == nil && interval >= 0 {
+		// zero turns scheduled backups off
+		serverManager.backupInterval = interval
+	}
+	if onStop, err := strconv.ParseBool(os.Getenv("BB_BACKUP_ON_STOP")); err == nil {
+		serverManager.backupOnStop = onStop
+	}
+	if limit, err := strconv.ParseInt(os.Getenv("BB_UPLOAD_LIMIT_MB"), 10, 64); err == nil && limit > 0 {
+		serverManager.uploadLimit = limit << 20
+	}
+	for env, keep := range map[string]*int{
+		"BB_BACKUP_KEEP_HOURLY": &serverManager.retention.hourly,
+		"BB_BACKUP_KEEP_DAILY":  &serverManager.retention.daily,
+		"BB_BACKUP_KEEP_WEEKLY": &serverManager.retention.weekly,
+	} {
+		if parsed, err := strconv.Atoi(os.Getenv(env)); err == nil && parsed >= 0 {
+			*keep = parsed
+		}
+	}
+
+	// pick up any servers left running by a previous run of the bot
+	restoreState(serverManager)
+	cleanScratchDir(importsDirName)
+	cleanScratchDir(exportsDirName)
+
+	go scheduleBackups(serverResponses)
+
+	debounce := defaultNotifyDebounce
+	if parsed, err := time.ParseDuration(os.Getenv("BB_NOTIFY_DEBOUNCE")); err == nil && parsed >= 0 {
+		debounce = parsed
+	}
+	playerEvents, _ := serverManager.events.subscribe()
+	go notifyPlayers(playerEvents, discordResponses, serverManager.notify, debounce)
 
 	go func() {
 		outgoingArrow := "<- "
@@ -203,8 +586,19 @@ func MakeServerManager(serverRequests <-chan *defs.ServerRequestOp, discordRespo
 				continue
 			}
 			responseMsg := action(serverManager, args)
+			saveState(serverManager)
+			response := serverManager.reply
+			serverManager.reply = nil
+			if responseMsg == "" {
+				// nothing worth telling discord about
+				continue
+			}
 			fmt.Println(outgoingArrow + responseMsg)
-			discordResponses <- responseMsg
+			if response == nil {
+				response = &defs.DiscordResponse{}
+			}
+			response.Content = responseMsg
+			discordResponses <- response
 		}
 	}()
 }
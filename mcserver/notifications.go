@@ -0,0 +1,173 @@
+package mcserver
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/matthewdavidrodgers/dbot-mk2/defs"
+	"github.com/matthewdavidrodgers/dbot-mk2/utils"
+)
+
+// notifySettingsFileName is where each discord server's notification settings are kept
+const notifySettingsFileName = "bb-notify.json"
+
+// defaultNotifyDebounce is how long a player can be gone before their leave is announced, unless BB_NOTIFY_DEBOUNCE
+// says otherwise. coming back sooner than that is treated as never having left
+const defaultNotifyDebounce = time.Minute
+
+// guildNotify is one discord server's settings for join and leave notifications. quiet hours run from QuietStart up
+// to QuietEnd, in the bot's local time, and are off when they're equal
+type guildNotify struct {
+	Enabled    bool   `json:"enabled"`
+	ChannelID  string `json:"channelId"`
+	QuietStart int    `json:"quietStart"`
+	QuietEnd   int    `json:"quietEnd"`
+}
+
+func (g *guildNotify) quiet(at time.Time) bool {
+	hour := at.Hour()
+	if g.QuietStart == g.QuietEnd {
+		return false
+	}
+	if g.QuietStart < g.QuietEnd {
+		return hour >= g.QuietStart && hour < g.QuietEnd
+	}
+	// quiet hours that run past midnight
+	return hour >= g.QuietStart || hour < g.QuietEnd
+}
+
+func (g *guildNotify) describeQuiet() string {
+	if g.QuietStart == g.QuietEnd {
+		return "none"
+	}
+	return fmt.Sprintf("%02d:00 to %02d:00", g.QuietStart, g.QuietEnd)
+}
+
+// notifySettings holds the notification settings of every discord server, keyed by guild id. the manager changes them
+// while the notifier reads them, so they're behind a lock
+type notifySettings struct {
+	lock   sync.Mutex
+	guilds map[string]*guildNotify
+}
+
+// parseQuietHours reads quiet hours like "23-7", or "off"
+func parseQuietHours(value string) (int, int, error) {
+	if value == "off" {
+		return 0, 0, nil
+	}
+	parts := strings.Split(value, "-")
+	if len(parts) == 2 {
+		start, startErr := strconv.Atoi(parts[0])
+		end, endErr := strconv.Atoi(parts[1])
+		if startErr == nil && endErr == nil && start >= 0 && start < 24 && end >= 0 && end < 24 {
+			return start, end, nil
+		}
+	}
+	return 0, 0, fmt.Errorf("quiet hours should look like 23-7 (from 11pm to 7am), or off")
+}
+
+func readNotifySettings() *notifySettings {
+	settings := &notifySettings{guilds: make(map[string]*guildNotify)}
+	contents, err := ioutil.ReadFile(notifySettingsFileName)
+	if os.IsNotExist(err) {
+		return settings
+	}
+	if err == nil {
+		err = json.Unmarshal(contents, &settings.guilds)
+	}
+	if err != nil {
+		fmt.Println("could not read notification settings", err)
+	}
+	return settings
+}
+
+// save writes the settings to disk. the caller holds the lock
+func (s *notifySettings) save() error {
+	contents, err := json.MarshalIndent(s.guilds, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(notifySettingsFileName, append(contents, '\n'), 0666)
+}
+
+// announce sends a message to every discord server that wants notifications and isn't in its quiet hours
+func (s *notifySettings) announce(discord chan<- *defs.DiscordResponse, message string) {
+	s.lock.Lock()
+	channels := make([]string, 0)
+	now := time.Now()
+	for _, guild := range s.guilds {
+		if guild.Enabled && guild.ChannelID != "" && !guild.quiet(now) {
+			channels = append(channels, guild.ChannelID)
+		}
+	}
+	s.lock.Unlock()
+
+	for _, channel := range channels {
+		discord <- &defs.DiscordResponse{Content: message, ChannelID: channel}
+	}
+}
+
+type playerKey struct {
+	world  string
+	player string
+}
+
+// pendingLeave is a leave waiting out the debounce before it's announced
+type pendingLeave struct {
+	id     int
+	leftAt time.Time
+}
+
+// notifyPlayers announces players joining and leaving, forever. a player who leaves and comes back within the
+// debounce is never announced as having left, nor as joining again
+func notifyPlayers(events <-chan *defs.GameEvent, discord chan<- *defs.DiscordResponse, settings *notifySettings, debounce time.Duration) {
+	joinedAt := make(map[playerKey]time.Time)
+	pending := make(map[playerKey]pendingLeave)
+	expired := make(chan pendingLeave)
+	expiredKeys := make(map[int]playerKey)
+	nextID := 0
+
+	for {
+		select {
+		case event := <-events:
+			key := playerKey{event.World, event.Player}
+			switch event.Code {
+			case defs.PlayerJoined:
+				if _, ok := pending[key]; ok {
+					// just a reconnect
+					delete(pending, key)
+					continue
+				}
+				joinedAt[key] = event.Time
+				settings.announce(discord, fmt.Sprintf("%s joined _%s_ (%s online)", event.Player, event.World, event.Args["online"]))
+			case defs.PlayerLeft:
+				leave := pendingLeave{id: nextID, leftAt: event.Time}
+				nextID++
+				pending[key] = leave
+				expiredKeys[leave.id] = key
+				time.AfterFunc(debounce, func() { expired <- leave })
+			}
+
+		case leave := <-expired:
+			key := expiredKeys[leave.id]
+			delete(expiredKeys, leave.id)
+			if current, ok := pending[key]; !ok || current.id != leave.id {
+				// they came back, or left again since
+				continue
+			}
+			delete(pending, key)
+			message := fmt.Sprintf("%s left _%s_", key.player, key.world)
+			if joined, ok := joinedAt[key]; ok {
+				message += " after " + utils.FormatDuration(leave.leftAt.Sub(joined))
+			}
+			delete(joinedAt, key)
+			settings.announce(discord, message)
+		}
+	}
+}
@@ -0,0 +1,183 @@
+package mcserver
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// pingStatus is what a server reports about itself through the server list ping
+type pingStatus struct {
+	version     string
+	protocol    int
+	online      int
+	max         int
+	motd        string
+	playerNames []string
+	latency     time.Duration
+}
+
+type pingResponse struct {
+	Version struct {
+		Name     string `json:"name"`
+		Protocol int    `json:"protocol"`
+	} `json:"version"`
+	Players struct {
+		Max    int `json:"max"`
+		Online int `json:"online"`
+		Sample []struct {
+			Name string `json:"name"`
+			ID   string `json:"id"`
+		} `json:"sample"`
+	} `json:"players"`
+	Description json.RawMessage `json:"description"`
+}
+
+// chatComponent is the json text format the server uses for its motd
+type chatComponent struct {
+	Text  string            `json:"text"`
+	Extra []json.RawMessage `json:"extra"`
+}
+
+var formattingCodeRegexp = regexp.MustCompile(`§.`)
+
+// maxPingResponseLength caps the status json we're willing to read, well above anything a real server sends
+const maxPingResponseLength = 1 << 20
+
+// pingServer performs a server list ping (handshake followed by a status request) against a minecraft server
+func pingServer(address string, timeout time.Duration) (*pingStatus, error) {
+	host, portString, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, err
+	}
+	port, err := strconv.Atoi(portString)
+	if err != nil {
+		return nil, err
+	}
+
+	started := time.Now()
+	conn, err := net.DialTimeout("tcp", address, timeout)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(timeout))
+
+	var handshake bytes.Buffer
+	writeVarInt(&handshake, 0x00)
+	// -1 asks the server to report its own protocol version instead of judging ours
+	writeVarInt(&handshake, -1)
+	writeVarInt(&handshake, int32(len(host)))
+	handshake.WriteString(host)
+	binary.Write(&handshake, binary.BigEndian, uint16(port))
+	writeVarInt(&handshake, 1)
+
+	var request bytes.Buffer
+	writeVarInt(&request, int32(handshake.Len()))
+	request.Write(handshake.Bytes())
+	// status request: a packet with just its id
+	writeVarInt(&request, 1)
+	writeVarInt(&request, 0x00)
+
+	if _, err := conn.Write(request.Bytes()); err != nil {
+		return nil, err
+	}
+
+	reader := bufio.NewReader(conn)
+	if _, err := readVarInt(reader); err != nil {
+		return nil, err
+	}
+	packetID, err := readVarInt(reader)
+	if err != nil {
+		return nil, err
+	}
+	if packetID != 0x00 {
+		return nil, fmt.Errorf("unexpected ping response packet 0x%02x", packetID)
+	}
+	length, err := readVarInt(reader)
+	if err != nil {
+		return nil, err
+	}
+	if length < 0 || length > maxPingResponseLength {
+		return nil, fmt.Errorf("ping response length %d is out of range", length)
+	}
+	body := make([]byte, length)
+	if _, err := io.ReadFull(reader, body); err != nil {
+		return nil, err
+	}
+
+	var resp pingResponse
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return nil, err
+	}
+
+	status := &pingStatus{
+		version:  resp.Version.Name,
+		protocol: resp.Version.Protocol,
+		online:   resp.Players.Online,
+		max:      resp.Players.Max,
+		motd:     formattingCodeRegexp.ReplaceAllString(flattenChat(resp.Description), ""),
+		latency:  time.Since(started),
+	}
+	for _, player := range resp.Players.Sample {
+		status.playerNames = append(status.playerNames, player.Name)
+	}
+	return status, nil
+}
+
+// flattenChat reduces a json text component, which may be a plain string or a nested object, to its plain text
+func flattenChat(raw json.RawMessage) string {
+	if len(raw) == 0 {
+		return ""
+	}
+	var text string
+	if err := json.Unmarshal(raw, &text); err == nil {
+		return text
+	}
+	var component chatComponent
+	if err := json.Unmarshal(raw, &component); err != nil {
+		return ""
+	}
+	var sb strings.Builder
+	sb.WriteString(component.Text)
+	for _, extra := range component.Extra {
+		sb.WriteString(flattenChat(extra))
+	}
+	return sb.String()
+}
+
+func writeVarInt(w *bytes.Buffer, value int32) {
+	v := uint32(value)
+	for {
+		if v&^0x7F == 0 {
+			w.WriteByte(byte(v))
+			return
+		}
+		w.WriteByte(byte(v&0x7F | 0x80))
+		v >>= 7
+	}
+}
+
+func readVarInt(r io.ByteReader) (int32, error) {
+	var result uint32
+	for i := 0; i < 5; i++ {
+		b, err := r.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		result |= uint32(b&0x7F) << (7 * uint(i))
+		if b&0x80 == 0 {
+			return int32(result), nil
+		}
+	}
+	return 0, errors.New("varint is too long")
+}
@@ -0,0 +1,73 @@
+package mcserver
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// defaultPorts is the pool of game ports handed out to servers unless BB_PORTS says otherwise
+var defaultPorts = []int{25565, 25566, 25567, 25568, 25569}
+
+// parsePortPool parses a comma separated list of ports and port ranges, e.g. "25565-25567,25600"
+func parsePortPool(spec string) ([]int, error) {
+	ports := make([]int, 0)
+	seen := make(map[int]bool)
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		low, high := part, part
+		if dash := strings.Index(part, "-"); dash >= 0 {
+			low, high = part[:dash], part[dash+1:]
+		}
+		first, err := strconv.Atoi(low)
+		if err != nil {
+			return nil, fmt.Errorf("invalid port \"%s\" in pool", low)
+		}
+		last, err := strconv.Atoi(high)
+		if err != nil {
+			return nil, fmt.Errorf("invalid port \"%s\" in pool", high)
+		}
+		if first < 1 || last+rconPortOffset > 65535 || first > last {
+			return nil, fmt.Errorf("invalid port range \"%s\" in pool", part)
+		}
+
+		for port := first; port <= last; port++ {
+			if !seen[port] {
+				seen[port] = true
+				ports = append(ports, port)
+			}
+		}
+	}
+
+	// every server also needs its rcon port, which mustn't be another server's game port
+	for _, port := range ports {
+		if seen[port+rconPortOffset] {
+			return nil, fmt.Errorf("port %d is in the pool, but it's the rcon port for %d", port+rconPortOffset, port)
+		}
+	}
+	if len(ports) == 0 {
+		return nil, fmt.Errorf("port pool \"%s\" is empty", spec)
+	}
+	return ports, nil
+}
+
+// freePort finds a port in the pool that no active server is using
+func freePort(m *manager) (int, bool) {
+	for _, port := range m.ports {
+		taken := false
+		for _, s := range m.servers {
+			if s.active() && s.port == port {
+				taken = true
+				break
+			}
+		}
+		if !taken {
+			return port, true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,200 @@
+package mcserver
+
+import (
+	"bufio"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/matthewdavidrodgers/dbot-mk2/utils"
+)
+
+type propertyKind int
+
+const (
+	boolProperty propertyKind = iota
+	intProperty
+	stringProperty
+	enumProperty
+)
+
+// propertySpec describes the values a server.properties key accepts
+type propertySpec struct {
+	kind   propertyKind
+	min    int
+	max    int
+	values []string
+}
+
+func boolSpec() propertySpec {
+	return propertySpec{kind: boolProperty}
+}
+
+func intSpec(min int, max int) propertySpec {
+	return propertySpec{kind: intProperty, min: min, max: max}
+}
+
+func stringSpec() propertySpec {
+	return propertySpec{kind: stringProperty}
+}
+
+func enumSpec(values ...string) propertySpec {
+	return propertySpec{kind: enumProperty, values: values}
+}
+
+// vanillaProperties is the schema of the server.properties keys the vanilla server understands
+var vanillaProperties = map[string]propertySpec{
+	"accepts-transfers":                 boolSpec(),
+	"allow-flight":                      boolSpec(),
+	"allow-nether":                      boolSpec(),
+	"broadcast-console-to-ops":          boolSpec(),
+	"broadcast-rcon-to-ops":             boolSpec(),
+	"bug-report-link":                   stringSpec(),
+	"difficulty":                        enumSpec("peaceful", "easy", "normal", "hard"),
+	"enable-command-block":              boolSpec(),
+	"enable-jmx-monitoring":             boolSpec(),
+	"enable-query":                      boolSpec(),
+	"enable-status":                     boolSpec(),
+	"enforce-secure-profile":            boolSpec(),
+	"enforce-whitelist":                 boolSpec(),
+	"entity-broadcast-range-percentage": intSpec(10, 1000),
+	"force-gamemode":                    boolSpec(),
+	"function-permission-level":         intSpec(1, 4),
+	"gamemode":                          enumSpec("survival", "creative", "adventure", "spectator"),
+	"generate-structures":               boolSpec(),
+	"generator-settings":                stringSpec(),
+	"hardcore":                          boolSpec(),
+	"hide-online-players":               boolSpec(),
+	"initial-disabled-packs":            stringSpec(),
+	"initial-enabled-packs":             stringSpec(),
+	"level-name":                        stringSpec(),
+	"level-seed":                        stringSpec(),
+	"level-type":                        enumSpec("minecraft:normal", "minecraft:flat", "minecraft:large_biomes", "minecraft:amplified", "minecraft:single_biome_surface", "default", "flat", "largebiomes", "amplified"),
+	"log-ips":                           boolSpec(),
+	"max-chained-neighbor-updates":      intSpec(math.MinInt32, math.MaxInt32),
+	"max-players":                       intSpec(0, math.MaxInt32),
+	"max-tick-time":                     intSpec(-1, math.MaxInt32),
+	"max-world-size":                    intSpec(1, 29999984),
+	"motd":                              stringSpec(),
+	"network-compression-threshold":     intSpec(-1, math.MaxInt32),
+	"online-mode":                       boolSpec(),
+	"op-permission-level":               intSpec(0, 4),
+	"player-idle-timeout":               intSpec(0, math.MaxInt32),
+	"prevent-proxy-connections":         boolSpec(),
+	"pvp":                               boolSpec(),
+	"query.port":                        intSpec(1, 65534),
+	"rate-limit":                        intSpec(0, math.MaxInt32),
+	"region-file-compression":           enumSpec("deflate", "lz4", "none"),
+	"require-resource-pack":             boolSpec(),
+	"resource-pack":                     stringSpec(),
+	"resource-pack-id":                  stringSpec(),
+	"resource-pack-prompt":              stringSpec(),
+	"resource-pack-sha1":                stringSpec(),
+	"server-ip":                         stringSpec(),
+	"simulation-distance":               intSpec(3, 32),
+	"spawn-animals":                     boolSpec(),
+	"spawn-monsters":                    boolSpec(),
+	"spawn-npcs":                        boolSpec(),
+	"spawn-protection":                  intSpec(0, math.MaxInt32),
+	"sync-chunk-writes":                 boolSpec(),
+	"text-filtering-config":             stringSpec(),
+	"use-native-transport":              boolSpec(),
+	"view-distance":                     intSpec(3, 32),
+	"white-list":                        boolSpec(),
+}
+
+// managedProperties are set by the bot itself every time a server starts, so changing them by hand would be pointless
+var managedProperties = map[string]bool{
+	"enable-rcon":   true,
+	"rcon.password": true,
+	"rcon.port":     true,
+	"server-port":   true,
+}
+
+// validateProperty checks a value against the schema for its key
+func validateProperty(key string, value string) error {
+	if managedProperties[key] {
+		return fmt.Errorf("%s is managed by the bot", key)
+	}
+	spec, ok := vanillaProperties[key]
+	if !ok {
+		return fmt.Errorf("%s is not a property the server knows about", key)
+	}
+	if strings.ContainsAny(value, "\r\n") {
+		// a line break would sneak a second property past the schema
+		return fmt.Errorf("%s can't contain line breaks", key)
+	}
+	if key == "level-name" && !worldNameRegexp.MatchString(value) {
+		// the level is a directory inside the world, and has to stay there
+		return fmt.Errorf("%s can only have letters, numbers, dashes and underscores", key)
+	}
+
+	switch spec.kind {
+	case boolProperty:
+		if value != "true" && value != "false" {
+			return fmt.Errorf("%s must be true or false", key)
+		}
+	case intProperty:
+		parsed, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("%s must be a whole number", key)
+		}
+		if parsed < spec.min || parsed > spec.max {
+			return fmt.Errorf("%s must be between %d and %d", key, spec.min, spec.max)
+		}
+	case enumProperty:
+		for _, allowed := range spec.values {
+			if value == allowed {
+				return nil
+			}
+		}
+		return fmt.Errorf("%s must be one of: %s", key, strings.Join(spec.values, ", "))
+	}
+	return nil
+}
+
+// readProperties reads every key=value pair in a server.properties file, in the order they appear
+func readProperties(propertiesFile string) ([]string, map[string]string, error) {
+	file, err := os.Open(propertiesFile)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer file.Close()
+
+	keys := make([]string, 0)
+	values := make(map[string]string)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		separator := strings.Index(line, "=")
+		if separator < 0 {
+			continue
+		}
+		key := line[:separator]
+		if _, seen := values[key]; !seen {
+			keys = append(keys, key)
+		}
+		values[key] = line[separator+1:]
+	}
+	return keys, values, scanner.Err()
+}
+
+// applyPendingProperties writes property changes that were queued while the world was running
+func applyPendingProperties(world string, config *worldConfig, propertiesFile string) error {
+	if len(config.PendingProperties) == 0 {
+		return nil
+	}
+	for key, value := range config.PendingProperties {
+		err := utils.SetNamedValueInTextFile(propertiesFile, key, value)
+		if err != nil {
+			return err
+		}
+	}
+	config.PendingProperties = nil
+	return writeWorldConfig(world, config)
+}
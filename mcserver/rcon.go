@@ -0,0 +1,134 @@
+package mcserver
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+const (
+	rconTypeResponse int32 = 0
+	rconTypeCommand  int32 = 2
+	rconTypeLogin    int32 = 3
+
+	// the vanilla server will not accept request bodies larger than this
+	rconMaxBodyLength = 1446
+	// nor will it send response packets larger than this
+	rconMaxPacketLength = 4096 + 10
+)
+
+// errRconAuth is returned when the server rejects the rcon password
+var errRconAuth = errors.New("rcon authentication failed")
+
+// rconClient is a connection to a minecraft server's console using the source rcon protocol
+type rconClient struct {
+	mu     sync.Mutex
+	conn   net.Conn
+	nextID int32
+}
+
+func dialRcon(address string, password string) (*rconClient, error) {
+	conn, err := net.DialTimeout("tcp", address, 5*time.Second)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &rconClient{conn: conn, nextID: 1}
+	id, err := c.send(rconTypeLogin, password)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	respID, _, _, err := c.receive()
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if respID == -1 || respID != id {
+		conn.Close()
+		return nil, errRconAuth
+	}
+
+	return c, nil
+}
+
+// command runs a console command on the server and returns its reply
+func (c *rconClient) command(cmd string) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(cmd) > rconMaxBodyLength {
+		return "", fmt.Errorf("rcon command is too long (%d bytes)", len(cmd))
+	}
+
+	id, err := c.send(rconTypeCommand, cmd)
+	if err != nil {
+		return "", err
+	}
+	respID, respType, body, err := c.receive()
+	if err != nil {
+		return "", err
+	}
+	if respID != id || respType != rconTypeResponse {
+		return "", fmt.Errorf("unexpected rcon response (id %d, type %d)", respID, respType)
+	}
+	return body, nil
+}
+
+func (c *rconClient) close() error {
+	return c.conn.Close()
+}
+
+func (c *rconClient) send(packetType int32, body string) (int32, error) {
+	id := c.nextID
+	c.nextID++
+
+	// length covers id, type, body and the two trailing null bytes
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.LittleEndian, int32(4+4+len(body)+2))
+	binary.Write(&buf, binary.LittleEndian, id)
+	binary.Write(&buf, binary.LittleEndian, packetType)
+	buf.WriteString(body)
+	buf.Write([]byte{0, 0})
+
+	c.conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+	_, err := c.conn.Write(buf.Bytes())
+	return id, err
+}
+
+func (c *rconClient) receive() (int32, int32, string, error) {
+	c.conn.SetReadDeadline(time.Now().Add(30 * time.Second))
+
+	var length int32
+	if err := binary.Read(c.conn, binary.LittleEndian, &length); err != nil {
+		return 0, 0, "", err
+	}
+	if length < 10 || length > rconMaxPacketLength {
+		return 0, 0, "", fmt.Errorf("malformed rcon packet length %d", length)
+	}
+
+	packet := make([]byte, length)
+	if _, err := io.ReadFull(c.conn, packet); err != nil {
+		return 0, 0, "", err
+	}
+
+	id := int32(binary.LittleEndian.Uint32(packet[0:4]))
+	packetType := int32(binary.LittleEndian.Uint32(packet[4:8]))
+	body := string(bytes.TrimRight(packet[8:], "\x00"))
+	return id, packetType, body, nil
+}
+
+func generateRconPassword() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,57 @@
+package mcserver
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/matthewdavidrodgers/dbot-mk2/defs"
+)
+
+// recordCrash notes a crash of a world and, if the world opted in to auto-restarts, schedules the next attempt. it
+// returns a message describing what will happen next, or an empty string if nothing will
+func recordCrash(m *manager, world string) string {
+	config, err := readWorldConfig(world)
+	if err != nil {
+		fmt.Println(err)
+		return "\nCOULD NOT READ THE RESTART POLICY, SO I'M NOT TOUCHING IT."
+	}
+	policy := config.AutoRestart
+	if !policy.Enabled {
+		return ""
+	}
+
+	now := time.Now()
+	recent := make([]time.Time, 0)
+	for _, crashedAt := range m.crashes[world] {
+		if now.Sub(crashedAt) < policy.crashWindow() {
+			recent = append(recent, crashedAt)
+		}
+	}
+	recent = append(recent, now)
+	m.crashes[world] = recent
+
+	if len(recent) >= policy.crashLimit() {
+		delete(m.crashes, world)
+		delete(m.restartAttempts, world)
+		return fmt.Sprintf("\nTHAT'S %d CRASHES IN %s. CRASH LOOP. I'M NOT RESTARTING IT AGAIN - SOMEONE NEEDS TO LOOK AT IT.", len(recent), policy.crashWindow())
+	}
+
+	attempt := m.restartAttempts[world] + 1
+	if attempt > policy.maxRetries() {
+		delete(m.restartAttempts, world)
+		return fmt.Sprintf("\nGAVE UP AFTER %d FAILED RESTART ATTEMPTS.", policy.maxRetries())
+	}
+	m.restartAttempts[world] = attempt
+
+	delay := policy.delay(attempt)
+	go func() {
+		time.Sleep(delay)
+		m.serverResponses <- &defs.ServerResponseOp{
+			Code: defs.AutoRestart,
+			Args: map[string]string{"world": world, "attempt": strconv.Itoa(attempt)},
+		}
+	}()
+
+	return fmt.Sprintf("\nAUTO-RESTART ATTEMPT %d/%d IN %s.", attempt, policy.maxRetries(), delay)
+}
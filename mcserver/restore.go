@@ -0,0 +1,85 @@
+package mcserver
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/matthewdavidrodgers/dbot-mk2/defs"
+	"github.com/matthewdavidrodgers/dbot-mk2/utils"
+)
+
+// restoreWorld unpacks one of a world's backups. if into is the world itself, the current world directory is moved
+// aside as a safety copy first (and put back if the restore fails); otherwise the backup becomes a new world
+func restoreWorld(notify chan<- *defs.ServerResponseOp, world string, id string, into string) {
+	args := map[string]string{"world": world, "id": id, "into": into}
+	fail := func(err error) {
+		fmt.Println("restore of", world, "failed:", err)
+		args["error"] = err.Error()
+		notify <- &defs.ServerResponseOp{Code: defs.RestoreFailure, Args: args}
+	}
+
+	pwd, err := os.Getwd()
+	if err != nil {
+		fail(err)
+		return
+	}
+	archive := filepath.Join(pwd, backupsDirName, world, id+".tar.gz")
+	if _, err := os.Stat(archive); err != nil {
+		fail(errors.New("there's no backup with that id"))
+		return
+	}
+
+	worldDir := filepath.Join(pwd, "bb-worlds", into)
+	safetyDir := ""
+	if into == world {
+		safetyDir = filepath.Join(pwd, backupsDirName, world, "pre-restore-"+time.Now().Format(backupIDFormat))
+		err = os.Rename(worldDir, safetyDir)
+		if err != nil {
+			fail(err)
+			return
+		}
+	}
+	rollback := func() {
+		os.RemoveAll(worldDir)
+		if safetyDir != "" {
+			os.Rename(safetyDir, worldDir)
+		}
+	}
+
+	err = os.Mkdir(worldDir, 0755)
+	if err == nil {
+		err = utils.ExtractTarGz(archive, worldDir)
+	}
+	if err == nil {
+		err = checkLevelData(worldDir)
+	}
+	if err == nil && into != world {
+		// the new world shouldn't share its original's rcon password
+		err = configureRcon(filepath.Join(worldDir, "server.properties"))
+	}
+	if err != nil {
+		rollback()
+		fail(err)
+		return
+	}
+
+	if safetyDir != "" {
+		args["safety"] = filepath.Base(safetyDir)
+	}
+	notify <- &defs.ServerResponseOp{Code: defs.RestoreSuccess, Args: args}
+}
+
+// checkLevelData makes sure a world directory holds a level the server can load
+func checkLevelData(worldDir string) error {
+	dir, err := levelDir(worldDir)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(filepath.Join(dir, "level.dat")); err != nil {
+		return fmt.Errorf("the world has no %s/level.dat", filepath.Base(dir))
+	}
+	return nil
+}
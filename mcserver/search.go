@@ -0,0 +1,153 @@
+package mcserver
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// limits on a log search, so the answer fits in a few discord messages
+const (
+	maxSearchMatches    = 10
+	searchContextLines  = 2
+	maxSearchLineLength = 300
+)
+
+// logLevels are the levels the server logs at, least severe first
+var logLevels = []string{"TRACE", "DEBUG", "INFO", "WARN", "ERROR", "FATAL"}
+
+// logLineRegexp picks the time and level out of a console line, i.e. "[12:34:56] [Server thread/WARN]: ..."
+var logLineRegexp = regexp.MustCompile(`^\[(\d{2}):(\d{2}):(\d{2})\] \[[^\]]*/([A-Z]+)\]`)
+
+// logFilter is what a log search looks for. a zero value matches every line
+type logFilter struct {
+	pattern  *regexp.Regexp
+	minLevel int
+	since    time.Time
+}
+
+// logMatch is a line a search found, with the lines around it
+type logMatch struct {
+	world   string
+	session string
+	at      time.Time
+	lines   []string
+}
+
+func levelIndex(level string) int {
+	for i, known := range logLevels {
+		if known == strings.ToUpper(level) {
+			return i
+		}
+	}
+	return -1
+}
+
+// parseSince reads how far back a search goes, i.e. "2h" or "3d"
+func parseSince(value string) (time.Duration, error) {
+	if strings.HasSuffix(value, "d") {
+		days, err := strconv.Atoi(strings.TrimSuffix(value, "d"))
+		if err != nil || days < 0 {
+			return 0, fmt.Errorf("%s is not a number of days", value)
+		}
+		return time.Duration(days) * 24 * time.Hour, nil
+	}
+	since, err := time.ParseDuration(value)
+	if err != nil || since < 0 {
+		return 0, fmt.Errorf("%s is not a duration like 30m, 2h or 3d", value)
+	}
+	return since, nil
+}
+
+// searchLogs searches the logs of the given sessions of a world, returning the latest matches (oldest first) and how
+// many matches there were in all
+func searchLogs(world string, sessions []*sessionInfo, filter logFilter) ([]logMatch, int, error) {
+	matches := make([]logMatch, 0)
+	total := 0
+	// sessions come newest first, but matches should read in order
+	for i := len(sessions) - 1; i >= 0; i-- {
+		session := sessions[i]
+		if !filter.since.IsZero() && session.EndedOn != nil && session.EndedOn.Before(filter.since) {
+			continue
+		}
+		found, err := searchSession(world, session, filter)
+		if err != nil {
+			return nil, 0, err
+		}
+		total += len(found)
+		matches = append(matches, found...)
+		if len(matches) > maxSearchMatches {
+			matches = matches[len(matches)-maxSearchMatches:]
+		}
+	}
+	return matches, total, nil
+}
+
+func searchSession(world string, session *sessionInfo, filter logFilter) ([]logMatch, error) {
+	path, err := sessionLogPath(world, session.ID)
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return []logMatch{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	lines := make([]string, 0)
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if len(line) > maxSearchLineLength {
+			line = line[:maxSearchLineLength] + "..."
+		}
+		lines = append(lines, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	matches := make([]logMatch, 0)
+	// lines without a time or level of their own (say, a stack trace) belong with the line before them
+	at := session.StartedOn
+	level := levelIndex("INFO")
+	for i, line := range lines {
+		if parts := logLineRegexp.FindStringSubmatch(line); parts != nil {
+			hour, _ := strconv.Atoi(parts[1])
+			minute, _ := strconv.Atoi(parts[2])
+			second, _ := strconv.Atoi(parts[3])
+			lineTime := time.Date(at.Year(), at.Month(), at.Day(), hour, minute, second, 0, at.Location())
+			if lineTime.Before(at.Add(-time.Minute)) {
+				// the clock went past midnight
+				lineTime = lineTime.AddDate(0, 0, 1)
+			}
+			at = lineTime
+			level = levelIndex(parts[4])
+		}
+
+		if level < filter.minLevel || (!filter.since.IsZero() && at.Before(filter.since)) {
+			continue
+		}
+		if filter.pattern != nil && !filter.pattern.MatchString(line) {
+			continue
+		}
+
+		first, last := i-searchContextLines, i+searchContextLines+1
+		if first < 0 {
+			first = 0
+		}
+		if last > len(lines) {
+			last = len(lines)
+		}
+		matches = append(matches, logMatch{world: world, session: session.ID, at: at, lines: lines[first:last]})
+	}
+	return matches, nil
+}
@@ -0,0 +1,172 @@
+package mcserver
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// sessionsDirName is where each run of a server gets its own log, kept as bb-sessions/<world>/<id>.log alongside a
+// <id>.json describing the run
+const sessionsDirName = "bb-sessions"
+
+// the reasons a session ended
+const (
+	exitStopped  = "stopped"
+	exitCrashed  = "crashed"
+	exitStarting = "crashed while starting"
+	exitKilled   = "killed"
+	exitLost     = "died while the bot was away"
+)
+
+// sessionInfo describes one run of a world's server. a session with no end is still going (or the bot lost track of
+// it)
+type sessionInfo struct {
+	World     string     `json:"world"`
+	ID        string     `json:"id"`
+	StartedOn time.Time  `json:"startedOn"`
+	EndedOn   *time.Time `json:"endedOn,omitempty"`
+	Exit      string     `json:"exit,omitempty"`
+}
+
+// sessionID names the session of a server started at a given time
+func sessionID(startedOn time.Time) string {
+	return startedOn.Format(backupIDFormat)
+}
+
+func sessionDir(world string) (string, error) {
+	pwd, err := os.Getwd()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(pwd, sessionsDirName, world), nil
+}
+
+func sessionLogPath(world string, id string) (string, error) {
+	dir, err := sessionDir(world)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, id+".log"), nil
+}
+
+// openSessionLog opens the log of the session started at a given time, starting the session if it's new
+func openSessionLog(world string, startedOn time.Time) (*os.File, error) {
+	dir, err := sessionDir(world)
+	if err != nil {
+		return nil, err
+	}
+	err = os.MkdirAll(dir, 0755)
+	if err != nil {
+		return nil, err
+	}
+
+	id := sessionID(startedOn)
+	metaPath := filepath.Join(dir, id+".json")
+	if _, err := os.Stat(metaPath); os.IsNotExist(err) {
+		err = writeSession(metaPath, &sessionInfo{World: world, ID: id, StartedOn: startedOn})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return os.OpenFile(filepath.Join(dir, id+".log"), os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0666)
+}
+
+// endSession records how the session started at a given time ended
+func endSession(world string, startedOn time.Time, exit string) error {
+	dir, err := sessionDir(world)
+	if err != nil {
+		return err
+	}
+	metaPath := filepath.Join(dir, sessionID(startedOn)+".json")
+	session, err := readSession(metaPath)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	session.EndedOn = &now
+	session.Exit = exit
+	return writeSession(metaPath, session)
+}
+
+func readSession(path string) (*sessionInfo, error) {
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	session := &sessionInfo{}
+	err = json.Unmarshal(contents, session)
+	return session, err
+}
+
+func writeSession(path string, session *sessionInfo) error {
+	contents, err := json.MarshalIndent(session, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, append(contents, '\n'), 0666)
+}
+
+// listSessions lists a world's sessions, newest first
+func listSessions(world string) ([]*sessionInfo, error) {
+	dir, err := sessionDir(world)
+	if err != nil {
+		return nil, err
+	}
+	files, err := ioutil.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return []*sessionInfo{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	sessions := make([]*sessionInfo, 0)
+	for _, file := range files {
+		if file.IsDir() || !strings.HasSuffix(file.Name(), ".json") {
+			continue
+		}
+		session, err := readSession(filepath.Join(dir, file.Name()))
+		if err != nil {
+			continue
+		}
+		// the directory is the world's, even if the world has been renamed since the session was written
+		session.World = world
+		sessions = append(sessions, session)
+	}
+	sort.Slice(sessions, func(i, j int) bool { return sessions[i].StartedOn.After(sessions[j].StartedOn) })
+	return sessions, nil
+}
+
+// findSession picks one of a world's sessions: the latest if selector is empty, the nth latest if it's a number, or
+// the one with that id otherwise
+func findSession(world string, selector string) (*sessionInfo, error) {
+	sessions, err := listSessions(world)
+	if err != nil {
+		return nil, err
+	}
+	if len(sessions) == 0 {
+		return nil, errors.New("_" + world + "_ has no sessions yet")
+	}
+	if selector == "" {
+		return sessions[0], nil
+	}
+	if n, err := strconv.Atoi(selector); err == nil {
+		if n < 1 || n > len(sessions) {
+			return nil, errors.New("_" + world + "_ only has " + strconv.Itoa(len(sessions)) + " sessions")
+		}
+		return sessions[n-1], nil
+	}
+	for _, session := range sessions {
+		if session.ID == selector {
+			return session, nil
+		}
+	}
+	return nil, errors.New("_" + world + "_ has no session " + selector)
+}
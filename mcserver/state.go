@@ -0,0 +1,254 @@
+package mcserver
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/matthewdavidrodgers/dbot-mk2/defs"
+	"github.com/matthewdavidrodgers/dbot-mk2/utils"
+)
+
+// stateFileName is where the manager keeps track of its servers, so it can find them again after the bot restarts
+const stateFileName = "bb-state.json"
+
+// how often to check whether an adopted server's process is still around, and for new lines in its log
+const (
+	adoptedProcessPollInterval = 2 * time.Second
+	adoptedLogPollInterval     = 500 * time.Millisecond
+)
+
+type savedServer struct {
+	World     string    `json:"world"`
+	PID       int       `json:"pid"`
+	Port      int       `json:"port"`
+	StartedOn time.Time `json:"startedOn"`
+	State     string    `json:"state"`
+}
+
+var stateKeys = map[serverStateCode]string{
+	idle:               "idle",
+	starting:           "starting",
+	running:            "running",
+	stopping:           "stopping",
+	crashed:            "crashed",
+	stoppingForcefully: "stoppingForcefully",
+	stoppedForcefully:  "stoppedForcefully",
+}
+
+// saveState writes the manager's servers to the state file
+func saveState(m *manager) {
+	saved := make([]savedServer, 0, len(m.servers))
+	for _, s := range m.servers {
+		saved = append(saved, savedServer{
+			World:     s.worldName,
+			PID:       s.pid,
+			Port:      s.port,
+			StartedOn: s.startedOn,
+			State:     stateKeys[s.state],
+		})
+	}
+	sort.Slice(saved, func(i, j int) bool { return saved[i].World < saved[j].World })
+
+	contents, err := json.MarshalIndent(saved, "", "  ")
+	if err != nil {
+		fmt.Println("could not save state", err)
+		return
+	}
+	// write then rename, so a crash mid-write never leaves a half written state file
+	err = ioutil.WriteFile(stateFileName+".tmp", append(contents, '\n'), 0666)
+	if err == nil {
+		err = os.Rename(stateFileName+".tmp", stateFileName)
+	}
+	if err != nil {
+		fmt.Println("could not save state", err)
+	}
+}
+
+// restoreState reads the state file left by a previous run of the bot, re-adopting any server process that's still
+// running its world, and marking the ones that died while the bot was gone as crashed
+func restoreState(m *manager) {
+	contents, err := ioutil.ReadFile(stateFileName)
+	if os.IsNotExist(err) {
+		return
+	}
+	if err != nil {
+		fmt.Println("could not read state", err)
+		return
+	}
+	var saved []savedServer
+	err = json.Unmarshal(contents, &saved)
+	if err != nil {
+		fmt.Println("could not read state", err)
+		return
+	}
+
+	for _, entry := range saved {
+		state, ok := idle, false
+		for code, key := range stateKeys {
+			if key == entry.State {
+				state, ok = code, true
+			}
+		}
+		if !ok || !worldExists(entry.World) {
+			continue
+		}
+
+		s := &server{state: state, startedOn: entry.StartedOn, worldName: entry.World, port: entry.Port}
+		if !s.active() {
+			m.servers[entry.World] = s
+			continue
+		}
+
+		worldDir, err := filepath.Abs(filepath.Join("bb-worlds", entry.World))
+		if err != nil || !processRunsWorld(entry.PID, worldDir) {
+			fmt.Println("server for", entry.World, "died while the bot was away")
+			exit := exitLost
+			if state == stopping {
+				s.state = idle
+				exit = exitStopped
+			} else if state == stoppingForcefully {
+				s.state = stoppedForcefully
+				exit = exitKilled
+			} else {
+				s.state = crashed
+			}
+			endSession(entry.World, entry.StartedOn, exit)
+			m.servers[entry.World] = s
+			continue
+		}
+
+		adopted, err := adoptServer(m.serverResponses, m.events, entry, state)
+		if err != nil {
+			fmt.Println("could not re-adopt server for", entry.World, err)
+			s.state = crashed
+			m.servers[entry.World] = s
+			continue
+		}
+		fmt.Println("re-adopted server for", entry.World, "with pid", entry.PID)
+		m.servers[entry.World] = adopted
+
+		if state == stopping {
+			go watchStopDeadline(m.serverResponses, adopted, m.stopTimeout)
+		} else if state == stoppingForcefully {
+			adopted.kill()
+		}
+	}
+}
+
+// processRunsWorld checks whether a process is alive and running in a world's directory, which guards against the
+// pid having been reused by something else. the directories are compared as files rather than by path, since the
+// bot's own working directory may be reached through a symlink that the process's cwd has already resolved
+func processRunsWorld(pid int, worldDir string) bool {
+	if pid <= 0 {
+		return false
+	}
+	cwd, err := os.Stat(fmt.Sprintf("/proc/%d/cwd", pid))
+	if err != nil {
+		return false
+	}
+	world, err := os.Stat(worldDir)
+	return err == nil && os.SameFile(cwd, world)
+}
+
+// adoptServer takes over a server process left running by a previous run of the bot. its console output went with
+// the old bot, so the server's own log file stands in for it
+func adoptServer(notify chan<- *defs.ServerResponseOp, events *eventBus, entry savedServer, state serverStateCode) (*server, error) {
+	pwd, err := os.Getwd()
+	if err != nil {
+		return nil, err
+	}
+	worldDir := filepath.Join(pwd, "bb-worlds", entry.World)
+
+	rconPassword, err := utils.GetNamedValueInTextFile(filepath.Join(worldDir, "server.properties"), "rcon.password")
+	if err != nil {
+		return nil, err
+	}
+	rconAddress := net.JoinHostPort("localhost", strconv.Itoa(entry.Port+rconPortOffset))
+
+	logFile, err := openSessionLog(entry.World, entry.StartedOn)
+	if err != nil {
+		return nil, err
+	}
+	logFile.WriteString("\n\n=== BB RE-ADOPTED SESSION " + time.Now().String() + " ===\n\n\n")
+
+	outputReader, outputWriter := io.Pipe()
+	stopTailing := make(chan struct{})
+	tailDone := make(chan struct{})
+	go func() {
+		defer close(tailDone)
+		// a server that was still starting may already have logged that it's done, so read it from the top
+		tailFile(filepath.Join(worldDir, "logs", "latest.log"), state == starting, outputWriter, stopTailing)
+	}()
+
+	process := serverProcess{
+		pid:       entry.PID,
+		startedOn: entry.StartedOn,
+		output:    outputReader,
+		wait: func() {
+			for processRunsWorld(entry.PID, worldDir) {
+				time.Sleep(adoptedProcessPollInterval)
+			}
+			close(stopTailing)
+			<-tailDone
+			outputWriter.Close()
+		},
+		kill: func() {
+			syscall.Kill(entry.PID, syscall.SIGKILL)
+		},
+	}
+	return superviseServer(notify, events, entry.World, entry.Port, state, rconAddress, rconPassword, logFile, process), nil
+}
+
+// tailFile copies whatever gets appended to a file into w until stop is closed, starting over if the file is
+// replaced (as the server does with its log when it starts)
+func tailFile(path string, fromStart bool, w io.Writer, stop <-chan struct{}) {
+	var file *os.File
+	defer func() {
+		if file != nil {
+			file.Close()
+		}
+	}()
+
+	for {
+		if file == nil {
+			opened, err := os.Open(path)
+			if err == nil {
+				file = opened
+				if !fromStart {
+					file.Seek(0, io.SeekEnd)
+				}
+				// anything that replaces this file is new, and should be read in full
+				fromStart = true
+			}
+		}
+		if file != nil {
+			io.Copy(w, file)
+
+			current, statErr := os.Stat(path)
+			opened, openedErr := file.Stat()
+			if statErr == nil && openedErr == nil && !os.SameFile(current, opened) {
+				file.Close()
+				file = nil
+				continue
+			}
+		}
+
+		select {
+		case <-stop:
+			if file != nil {
+				io.Copy(w, file)
+			}
+			return
+		case <-time.After(adoptedLogPollInterval):
+		}
+	}
+}
@@ -0,0 +1,32 @@
+package mcserver
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestProcessRunsWorld(t *testing.T) {
+	pwd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	link := filepath.Join(t.TempDir(), "link")
+	err = os.Symlink(pwd, link)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !processRunsWorld(os.Getpid(), pwd) {
+		t.Error("didn't match the process's own directory")
+	}
+	if !processRunsWorld(os.Getpid(), link) {
+		t.Error("didn't match the process's directory through a symlink")
+	}
+	if processRunsWorld(os.Getpid(), t.TempDir()) {
+		t.Error("matched a directory the process isn't in")
+	}
+	if processRunsWorld(0, pwd) {
+		t.Error("matched a pid that can't be running")
+	}
+}
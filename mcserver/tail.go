@@ -0,0 +1,79 @@
+package mcserver
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/matthewdavidrodgers/dbot-mk2/defs"
+)
+
+// tailBatchInterval is how often tailed console lines are posted, which keeps well clear of discord's rate limits
+const tailBatchInterval = 3 * time.Second
+
+// tailBatchLength is the most console output posted per batch. it leaves room in discord's message limit for the
+// code block around it
+const tailBatchLength = 1900
+
+// logTail streams a running server's console into a discord channel
+type logTail struct {
+	world   string
+	channel string
+	stop    chan struct{}
+}
+
+// tailConsole posts a server's console lines to a discord channel in batches, until the tail is stopped or the server
+// exits. lines that don't fit in a batch are skipped, and the batch says how many
+func tailConsole(notify chan<- *defs.ServerResponseOp, discord chan<- *defs.DiscordResponse, s *server, tail *logTail) {
+	var lock sync.Mutex
+	lines := make([]string, 0)
+	cancel := s.listen(func(line string) {
+		lock.Lock()
+		lines = append(lines, line)
+		lock.Unlock()
+	})
+	defer cancel()
+
+	flush := func() {
+		lock.Lock()
+		batch := lines
+		lines = make([]string, 0)
+		lock.Unlock()
+		if len(batch) == 0 {
+			return
+		}
+
+		// keep the newest lines that fit
+		length, first := 0, len(batch)
+		for first > 0 && length+len(batch[first-1])+1 <= tailBatchLength {
+			first--
+			length += len(batch[first]) + 1
+		}
+		content := ""
+		if first > 0 {
+			content = "_(" + strconv.Itoa(first) + " lines skipped)_\n"
+		}
+		content += "```\n" + strings.Join(batch[first:], "\n") + "\n```"
+		discord <- &defs.DiscordResponse{Content: content, ChannelID: tail.channel}
+	}
+
+	ticker := time.NewTicker(tailBatchInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			flush()
+		case <-tail.stop:
+			flush()
+			return
+		case <-s.exited:
+			flush()
+			notify <- &defs.ServerResponseOp{
+				Code: defs.TailEnded,
+				Args: map[string]string{"world": tail.world},
+			}
+			return
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package mcserver
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// worldUsage is how much disk a world takes up, and when it was last played
+type worldUsage struct {
+	total      int64
+	overworld  int64
+	nether     int64
+	end        int64
+	lastPlayed time.Time
+}
+
+// measureWorld adds up the size of a world's directory and each of its level's dimensions. the last played time comes
+// from level.dat, or when level.dat was last written if it can't be read
+func measureWorld(world string) (*worldUsage, error) {
+	pwd, err := os.Getwd()
+	if err != nil {
+		return nil, err
+	}
+	worldDir := filepath.Join(pwd, "bb-worlds", world)
+	usage := &worldUsage{}
+
+	usage.total, err = dirSize(worldDir)
+	if err != nil {
+		return nil, err
+	}
+
+	level, err := levelDir(worldDir)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(level); os.IsNotExist(err) {
+		// never generated, so there's nothing more to measure
+		return usage, nil
+	}
+	levelSize, err := dirSize(level)
+	if err != nil {
+		return nil, err
+	}
+	usage.nether, _ = dirSize(filepath.Join(level, "DIM-1"))
+	usage.end, _ = dirSize(filepath.Join(level, "DIM1"))
+	usage.overworld = levelSize - usage.nether - usage.end
+
+	if info, err := readLevelInfo(world); err == nil && !info.lastPlayed.IsZero() {
+		usage.lastPlayed = info.lastPlayed
+	} else if stat, err := os.Stat(filepath.Join(level, "level.dat")); err == nil {
+		usage.lastPlayed = stat.ModTime()
+	}
+	return usage, nil
+}
+
+// dirSize adds up the sizes of the files under a directory. a directory that doesn't exist is empty
+func dirSize(dir string) (int64, error) {
+	var size int64
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if info.Mode().IsRegular() {
+			size += info.Size()
+		}
+		return nil
+	})
+	return size, err
+}
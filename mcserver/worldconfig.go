@@ -0,0 +1,347 @@
+package mcserver
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// worldConfigFileName is the name of the bot's own settings file, kept alongside server.properties in each world
+const worldConfigFileName = "bb.json"
+
+// worldConfig holds the bot's per-world settings. anything missing from the file falls back to a default
+type worldConfig struct {
+	// Jar is the name of the registry jar the world is pinned to. worlds without one run on the legacy server.jar
+	Jar string `json:"jar,omitempty"`
+	// Java is the java binary to launch the server with
+	Java string `json:"java,omitempty"`
+	// MinHeap and MaxHeap are the sizes given to -Xms and -Xmx, e.g. 512M or 4G
+	MinHeap    string   `json:"minHeap,omitempty"`
+	MaxHeap    string   `json:"maxHeap,omitempty"`
+	JVMFlags   []string `json:"jvmFlags,omitempty"`
+	ServerArgs []string `json:"serverArgs,omitempty"`
+
+	AutoRestart restartPolicy `json:"autoRestart"`
+
+	// PendingProperties are server.properties changes made while the world was running, applied on its next start
+	PendingProperties map[string]string `json:"pendingProperties,omitempty"`
+}
+
+const (
+	defaultJava    = "java"
+	defaultMinHeap = "512M"
+	defaultMaxHeap = "1024M"
+)
+
+// jvmFlagPresets are well known sets of jvm flags that can be applied by name
+var jvmFlagPresets = map[string][]string{
+	// https://docs.papermc.io/paper/aikars-flags
+	"aikar": {
+		"-XX:+UseG1GC",
+		"-XX:+ParallelRefProcEnabled",
+		"-XX:MaxGCPauseMillis=200",
+		"-XX:+UnlockExperimentalVMOptions",
+		"-XX:+DisableExplicitGC",
+		"-XX:+AlwaysPreTouch",
+		"-XX:G1NewSizePercent=30",
+		"-XX:G1MaxNewSizePercent=40",
+		"-XX:G1HeapRegionSize=8M",
+		"-XX:G1ReservePercent=20",
+		"-XX:G1HeapWastePercent=5",
+		"-XX:G1MixedGCCountTarget=4",
+		"-XX:InitiatingHeapOccupancyPercent=15",
+		"-XX:G1MixedGCLiveThresholdPercent=90",
+		"-XX:G1RSetUpdatingPauseIntervalMillis=100",
+		"-XX:SurvivorRatio=32",
+		"-XX:+PerfDisableSharedMem",
+		"-XX:MaxTenuringThreshold=1",
+		"-Dusing.aikars.flags=https://mcflags.emc.gs",
+		"-Daikars.new.flags=true",
+	},
+	"none": {},
+}
+
+var heapSizeRegexp = regexp.MustCompile(`^[0-9]+[KkMmGg]?$`)
+
+// jvmOptionRegexp matches the -XX options and -D properties that can be set from discord, provided their name is one of
+// safeJVMOptions. values are kept to numbers and sizes, or for properties to a single word, so none can be a path
+var jvmOptionRegexp = regexp.MustCompile(`^(?:-XX:[+-]?([A-Za-z0-9]+)(?:=[0-9]+[KkMmGg]?)?|-D([A-Za-z0-9_.]+)=[A-Za-z0-9_.:/-]*)$`)
+
+// safeJVMOptions are the jvm options that only tune the jvm. anything else may run code or write files of its choosing
+// (-XX:OnError, --patch-module, -Xlog:gc:file= and so on), so it can only be set by editing bb.json by hand
+var safeJVMOptions = map[string]bool{
+	"UseG1GC":                           true,
+	"UseZGC":                            true,
+	"ZGenerational":                     true,
+	"UseShenandoahGC":                   true,
+	"UseParallelGC":                     true,
+	"ParallelRefProcEnabled":            true,
+	"MaxGCPauseMillis":                  true,
+	"UnlockExperimentalVMOptions":       true,
+	"DisableExplicitGC":                 true,
+	"AlwaysPreTouch":                    true,
+	"G1NewSizePercent":                  true,
+	"G1MaxNewSizePercent":               true,
+	"G1HeapRegionSize":                  true,
+	"G1ReservePercent":                  true,
+	"G1HeapWastePercent":                true,
+	"G1MixedGCCountTarget":              true,
+	"InitiatingHeapOccupancyPercent":    true,
+	"G1MixedGCLiveThresholdPercent":     true,
+	"G1RSetUpdatingPauseIntervalMillis": true,
+	"SurvivorRatio":                     true,
+	"PerfDisableSharedMem":              true,
+	"MaxTenuringThreshold":              true,
+	"UseStringDeduplication":            true,
+	"UseCompressedOops":                 true,
+	"ParallelGCThreads":                 true,
+	"ConcGCThreads":                     true,
+	"MaxMetaspaceSize":                  true,
+	"ReservedCodeCacheSize":             true,
+	"using.aikars.flags":                true,
+	"aikars.new.flags":                  true,
+	"file.encoding":                     true,
+	"user.timezone":                     true,
+}
+
+// safeServerArgs are the server arguments that can be set from discord. the rest can point the server at other
+// directories (--universe, --world, paper's --plugins) so can only be set by editing bb.json by hand
+var safeServerArgs = map[string]bool{
+	"--nogui":        true,
+	"nogui":          true,
+	"--bonusChest":   true,
+	"--eraseCache":   true,
+	"--forceUpgrade": true,
+	"--safeMode":     true,
+}
+
+// safeJVMFlag checks a jvm flag against jvmOptionRegexp and safeJVMOptions
+func safeJVMFlag(flag string) bool {
+	match := jvmOptionRegexp.FindStringSubmatch(flag)
+	if match == nil {
+		return false
+	}
+	return safeJVMOptions[match[1]+match[2]]
+}
+
+// allowedJavas lists the java binaries worlds may be switched to, which the operator gives as a comma separated
+// BB_JAVA_ALLOWED. without it, java can only be changed by editing bb.json by hand
+func allowedJavas() []string {
+	return splitList(os.Getenv("BB_JAVA_ALLOWED"))
+}
+
+func (c *worldConfig) java() string {
+	if c.Java != "" {
+		return c.Java
+	}
+	return defaultJava
+}
+
+func (c *worldConfig) minHeap() string {
+	if c.MinHeap != "" {
+		return c.MinHeap
+	}
+	return defaultMinHeap
+}
+
+func (c *worldConfig) maxHeap() string {
+	if c.MaxHeap != "" {
+		return c.MaxHeap
+	}
+	return defaultMaxHeap
+}
+
+// launchCommand builds the command that runs the given server jar with this world's settings
+func (c *worldConfig) launchCommand(jar string, serverArgs ...string) *exec.Cmd {
+	args := []string{"-Xmx" + c.maxHeap(), "-Xms" + c.minHeap()}
+	args = append(args, c.JVMFlags...)
+	args = append(args, "-jar", jar)
+	args = append(args, serverArgs...)
+	args = append(args, c.ServerArgs...)
+	return exec.Command(c.java(), args...)
+}
+
+// set changes one launch setting by name, as given to the config command
+func (c *worldConfig) set(key string, value string) error {
+	switch key {
+	case "jar":
+		if value != "" && !jarExists(value) {
+			return fmt.Errorf("there's no jar called \"%s\". see the versions command", value)
+		}
+		c.Jar = value
+	case "java":
+		allowed := allowedJavas()
+		valid := value == ""
+		for _, java := range allowed {
+			if value == java {
+				valid = true
+			}
+		}
+		if !valid {
+			if len(allowed) == 0 {
+				return fmt.Errorf("java can't be changed from discord. edit the world's bb.json by hand")
+			}
+			return fmt.Errorf("java must be one of: %s", strings.Join(allowed, ", "))
+		}
+		c.Java = value
+	case "xms", "xmx":
+		if value != "" && !heapSizeRegexp.MatchString(value) {
+			return fmt.Errorf("%s must be a size like 512M or 4G", key)
+		}
+		if key == "xms" {
+			c.MinHeap = value
+		} else {
+			c.MaxHeap = value
+		}
+	case "flags":
+		values := splitList(value)
+		for _, v := range values {
+			if !safeJVMFlag(v) {
+				return fmt.Errorf("\"%s\" can't be set from discord. edit the world's bb.json by hand", v)
+			}
+		}
+		c.JVMFlags = values
+	case "args":
+		values := splitList(value)
+		for _, v := range values {
+			if !safeServerArgs[v] {
+				return fmt.Errorf("\"%s\" can't be set from discord. edit the world's bb.json by hand", v)
+			}
+		}
+		c.ServerArgs = values
+	case "preset":
+		flags, ok := jvmFlagPresets[value]
+		if !ok {
+			return fmt.Errorf("unknown preset \"%s\"", value)
+		}
+		c.JVMFlags = append([]string{}, flags...)
+	default:
+		return fmt.Errorf("unknown setting \"%s\"", key)
+	}
+	return nil
+}
+
+func splitList(value string) []string {
+	values := make([]string, 0)
+	for _, v := range strings.Split(value, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+// restartPolicy controls whether and how a crashed server is brought back up automatically
+type restartPolicy struct {
+	Enabled bool `json:"enabled"`
+	// MaxRetries is how many restarts in a row may fail before giving up
+	MaxRetries int `json:"maxRetries,omitempty"`
+	// Backoff is the delay before the first restart attempt, doubled for every attempt after it
+	Backoff    string `json:"backoff,omitempty"`
+	MaxBackoff string `json:"maxBackoff,omitempty"`
+	// CrashLimit crashes within CrashWindow count as a crash loop, and stop any further restarts
+	CrashLimit  int    `json:"crashLimit,omitempty"`
+	CrashWindow string `json:"crashWindow,omitempty"`
+}
+
+const (
+	defaultMaxRetries  = 3
+	defaultBackoff     = 10 * time.Second
+	defaultMaxBackoff  = 5 * time.Minute
+	defaultCrashLimit  = 3
+	defaultCrashWindow = 30 * time.Minute
+)
+
+func (p restartPolicy) maxRetries() int {
+	if p.MaxRetries > 0 {
+		return p.MaxRetries
+	}
+	return defaultMaxRetries
+}
+
+func (p restartPolicy) backoff() time.Duration {
+	return parseDurationOr(p.Backoff, defaultBackoff)
+}
+
+func (p restartPolicy) maxBackoff() time.Duration {
+	return parseDurationOr(p.MaxBackoff, defaultMaxBackoff)
+}
+
+func (p restartPolicy) crashLimit() int {
+	if p.CrashLimit > 0 {
+		return p.CrashLimit
+	}
+	return defaultCrashLimit
+}
+
+func (p restartPolicy) crashWindow() time.Duration {
+	return parseDurationOr(p.CrashWindow, defaultCrashWindow)
+}
+
+// delay is how long to wait before the given restart attempt (starting at 1)
+func (p restartPolicy) delay(attempt int) time.Duration {
+	delay := p.backoff()
+	for i := 1; i < attempt && delay < p.maxBackoff(); i++ {
+		delay *= 2
+	}
+	if delay > p.maxBackoff() {
+		delay = p.maxBackoff()
+	}
+	return delay
+}
+
+func parseDurationOr(value string, fallback time.Duration) time.Duration {
+	if parsed, err := time.ParseDuration(value); err == nil && parsed > 0 {
+		return parsed
+	}
+	return fallback
+}
+
+func worldConfigPath(world string) (string, error) {
+	pwd, err := os.Getwd()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(pwd, "bb-worlds", world, worldConfigFileName), nil
+}
+
+// readWorldConfig reads a world's bot settings, treating a missing file as all defaults
+func readWorldConfig(world string) (*worldConfig, error) {
+	path, err := worldConfigPath(world)
+	if err != nil {
+		return nil, err
+	}
+
+	config := &worldConfig{}
+	contents, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return config, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	err = json.Unmarshal(contents, config)
+	if err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+func writeWorldConfig(world string, config *worldConfig) error {
+	path, err := worldConfigPath(world)
+	if err != nil {
+		return err
+	}
+
+	contents, err := json.MarshalIndent(config, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, append(contents, '\n'), 0666)
+}
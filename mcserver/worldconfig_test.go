@@ -0,0 +1,49 @@
+package mcserver
+
+import "testing"
+
+func TestSetFlags(t *testing.T) {
+	cases := []struct {
+		key   string
+		value string
+		ok    bool
+	}{
+		{"flags", "-XX:+UseG1GC, -XX:MaxGCPauseMillis=200", true},
+		{"flags", "-XX:G1HeapRegionSize=8M,-Dfile.encoding=UTF-8", true},
+		{"flags", "", true},
+		{"flags", "-XX:OnError=touch /tmp/pwned", false},
+		{"flags", "-XX:OnOutOfMemoryError=sh", false},
+		{"flags", "-XX:ErrorFile=/tmp/err.log", false},
+		{"flags", "-XX:LogFile=/tmp/jvm.log", false},
+		{"flags", "-Xlog:gc:file=/tmp/gc.log", false},
+		{"flags", "--patch-module=java.base=bb-worlds/evil/world", false},
+		{"flags", "--upgrade-module-path=bb-worlds/evil/world", false},
+		{"flags", "-javaagent:bb-worlds/evil/agent.jar", false},
+		{"flags", "-Dlog4j.configurationFile=bb-worlds/evil/log4j.xml", false},
+		{"flags", "-XX:MaxGCPauseMillis=/tmp", false},
+		{"args", "--nogui, --forceUpgrade", true},
+		{"args", "--universe /elsewhere", false},
+		{"args", "--plugins", false},
+		{"args", "--world", false},
+	}
+	for _, c := range cases {
+		config := &worldConfig{}
+		err := config.set(c.key, c.value)
+		if c.ok && err != nil {
+			t.Errorf("%s=%q: unexpected error %v", c.key, c.value, err)
+		}
+		if !c.ok && err == nil {
+			t.Errorf("%s=%q: expected an error", c.key, c.value)
+		}
+	}
+}
+
+func TestPresetsAreSafe(t *testing.T) {
+	for name, flags := range jvmFlagPresets {
+		for _, flag := range flags {
+			if !safeJVMFlag(flag) {
+				t.Errorf("preset %s has %q, which can't be set from discord", name, flag)
+			}
+		}
+	}
+}
@@ -0,0 +1,299 @@
+// Package nbt decodes minecraft's named binary tag format, the format of level.dat and most other minecraft save
+// data
+package nbt
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+)
+
+// tag types, in the order the format numbers them
+const (
+	tagEnd byte = iota
+	tagByte
+	tagShort
+	tagInt
+	tagLong
+	tagFloat
+	tagDouble
+	tagByteArray
+	tagString
+	tagList
+	tagCompound
+	tagIntArray
+	tagLongArray
+)
+
+// limits on what a file can ask for, so a corrupt or hostile one can't exhaust memory
+const (
+	maxDepth       = 512
+	maxArrayLength = 1 << 24
+)
+
+// ErrMalformed is returned when the data isn't valid nbt
+var ErrMalformed = errors.New("malformed nbt")
+
+// Compound is a decoded compound tag. values are int8, int16, int32, int64, float32, float64, string, []int8,
+// []int32, []int64, []interface{} (lists) or Compound
+type Compound map[string]interface{}
+
+// Compound gets a nested compound, or nil if there isn't one under the key
+func (c Compound) Compound(key string) Compound {
+	value, _ := c[key].(Compound)
+	return value
+}
+
+// String gets a string, or "" if there isn't one under the key
+func (c Compound) String(key string) string {
+	value, _ := c[key].(string)
+	return value
+}
+
+// Int gets any whole number under the key as an int64, and whether there was one
+func (c Compound) Int(key string) (int64, bool) {
+	switch value := c[key].(type) {
+	case int8:
+		return int64(value), true
+	case int16:
+		return int64(value), true
+	case int32:
+		return int64(value), true
+	case int64:
+		return value, true
+	}
+	return 0, false
+}
+
+// IntArray gets an int array, or nil if there isn't one under the key
+func (c Compound) IntArray(key string) []int32 {
+	value, _ := c[key].([]int32)
+	return value
+}
+
+// ReadFile decodes an nbt file, gzipped or not, returning its root compound
+func ReadFile(path string) (Compound, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	magic, err := reader.Peek(2)
+	if err != nil {
+		return nil, err
+	}
+	if magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(reader)
+		if err != nil {
+			return nil, err
+		}
+		defer gz.Close()
+		return Read(gz)
+	}
+	return Read(reader)
+}
+
+// Read decodes uncompressed nbt, returning its root compound
+func Read(r io.Reader) (Compound, error) {
+	d := &decoder{r: r}
+	tagType, err := d.byte()
+	if err != nil {
+		return nil, err
+	}
+	if tagType != tagCompound {
+		return nil, fmt.Errorf("%w: root is not a compound", ErrMalformed)
+	}
+	_, err = d.string()
+	if err != nil {
+		return nil, err
+	}
+	return d.compound(0)
+}
+
+type decoder struct {
+	r   io.Reader
+	buf [8]byte
+}
+
+func (d *decoder) read(n int) ([]byte, error) {
+	_, err := io.ReadFull(d.r, d.buf[:n])
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return d.buf[:n], err
+}
+
+func (d *decoder) byte() (byte, error) {
+	b, err := d.read(1)
+	if err != nil {
+		return 0, err
+	}
+	return b[0], nil
+}
+
+func (d *decoder) short() (int16, error) {
+	b, err := d.read(2)
+	if err != nil {
+		return 0, err
+	}
+	return int16(binary.BigEndian.Uint16(b)), nil
+}
+
+func (d *decoder) int() (int32, error) {
+	b, err := d.read(4)
+	if err != nil {
+		return 0, err
+	}
+	return int32(binary.BigEndian.Uint32(b)), nil
+}
+
+func (d *decoder) long() (int64, error) {
+	b, err := d.read(8)
+	if err != nil {
+		return 0, err
+	}
+	return int64(binary.BigEndian.Uint64(b)), nil
+}
+
+func (d *decoder) length() (int, error) {
+	n, err := d.int()
+	if err != nil {
+		return 0, err
+	}
+	if n < 0 || n > maxArrayLength {
+		return 0, fmt.Errorf("%w: length %d is out of bounds", ErrMalformed, n)
+	}
+	return int(n), nil
+}
+
+func (d *decoder) string() (string, error) {
+	n, err := d.short()
+	if err != nil {
+		return "", err
+	}
+	// lengths are unsigned
+	b := make([]byte, uint16(n))
+	_, err = io.ReadFull(d.r, b)
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return string(b), err
+}
+
+func (d *decoder) compound(depth int) (Compound, error) {
+	c := make(Compound)
+	for {
+		tagType, err := d.byte()
+		if err != nil {
+			return nil, err
+		}
+		if tagType == tagEnd {
+			return c, nil
+		}
+		name, err := d.string()
+		if err != nil {
+			return nil, err
+		}
+		c[name], err = d.payload(tagType, depth+1)
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (d *decoder) payload(tagType byte, depth int) (interface{}, error) {
+	if depth > maxDepth {
+		return nil, fmt.Errorf("%w: nested too deeply", ErrMalformed)
+	}
+
+	switch tagType {
+	case tagByte:
+		b, err := d.byte()
+		return int8(b), err
+	case tagShort:
+		return d.short()
+	case tagInt:
+		return d.int()
+	case tagLong:
+		return d.long()
+	case tagFloat:
+		v, err := d.int()
+		return math.Float32frombits(uint32(v)), err
+	case tagDouble:
+		v, err := d.long()
+		return math.Float64frombits(uint64(v)), err
+	case tagByteArray:
+		n, err := d.length()
+		if err != nil {
+			return nil, err
+		}
+		b := make([]byte, n)
+		_, err = io.ReadFull(d.r, b)
+		if err != nil {
+			return nil, io.ErrUnexpectedEOF
+		}
+		values := make([]int8, n)
+		for i := range b {
+			values[i] = int8(b[i])
+		}
+		return values, nil
+	case tagString:
+		return d.string()
+	case tagList:
+		elemType, err := d.byte()
+		if err != nil {
+			return nil, err
+		}
+		n, err := d.length()
+		if err != nil {
+			return nil, err
+		}
+		values := make([]interface{}, 0)
+		for i := 0; i < n; i++ {
+			value, err := d.payload(elemType, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, value)
+		}
+		return values, nil
+	case tagCompound:
+		return d.compound(depth)
+	case tagIntArray:
+		n, err := d.length()
+		if err != nil {
+			return nil, err
+		}
+		values := make([]int32, 0)
+		for i := 0; i < n; i++ {
+			v, err := d.int()
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, v)
+		}
+		return values, nil
+	case tagLongArray:
+		n, err := d.length()
+		if err != nil {
+			return nil, err
+		}
+		values := make([]int64, 0)
+		for i := 0; i < n; i++ {
+			v, err := d.long()
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, v)
+		}
+		return values, nil
+	}
+	return nil, fmt.Errorf("%w: unknown tag type %d", ErrMalformed, tagType)
+}
@@ -0,0 +1,130 @@
+package nbt
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+)
+
+// builder writes nbt by hand, a field at a time
+type builder struct {
+	bytes.Buffer
+}
+
+func (b *builder) tag(tagType byte, name string) *builder {
+	b.WriteByte(tagType)
+	return b.str(name)
+}
+
+func (b *builder) str(s string) *builder {
+	b.num(uint16(len(s)))
+	b.WriteString(s)
+	return b
+}
+
+func (b *builder) num(v interface{}) *builder {
+	binary.Write(&b.Buffer, binary.BigEndian, v)
+	return b
+}
+
+func (b *builder) end() *builder {
+	b.WriteByte(tagEnd)
+	return b
+}
+
+func TestRead(t *testing.T) {
+	b := &builder{}
+	b.tag(tagCompound, "")
+	b.tag(tagByte, "byte").num(int8(-3))
+	b.tag(tagShort, "short").num(int16(-300))
+	b.tag(tagInt, "int").num(int32(70000))
+	b.tag(tagLong, "long").num(int64(-5000000000))
+	b.tag(tagFloat, "float").num(float32(1.5))
+	b.tag(tagDouble, "double").num(float64(-2.25))
+	b.tag(tagByteArray, "bytes").num(int32(3)).num([]int8{1, -1, 2})
+	b.tag(tagString, "string").str("héllo")
+	b.tag(tagList, "list").num(tagString).num(int32(2)).str("a").str("b")
+	b.tag(tagList, "empty").num(tagEnd).num(int32(0))
+	b.tag(tagCompound, "nested").tag(tagInt, "x").num(int32(7)).end()
+	b.tag(tagIntArray, "ints").num(int32(3)).num([]int32{1, -64, 3})
+	b.tag(tagLongArray, "longs").num(int32(2)).num([]int64{1 << 40, -1})
+	b.end()
+
+	got, err := Read(bytes.NewReader(b.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Compound{
+		"byte":   int8(-3),
+		"short":  int16(-300),
+		"int":    int32(70000),
+		"long":   int64(-5000000000),
+		"float":  float32(1.5),
+		"double": float64(-2.25),
+		"bytes":  []int8{1, -1, 2},
+		"string": "héllo",
+		"list":   []interface{}{"a", "b"},
+		"empty":  []interface{}{},
+		"nested": Compound{"x": int32(7)},
+		"ints":   []int32{1, -64, 3},
+		"longs":  []int64{1 << 40, -1},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %#v\nwant %#v", got, want)
+	}
+
+	if got.Compound("nested") == nil || got.Compound("missing") != nil {
+		t.Error("Compound didn't find the nested compound")
+	}
+	for _, key := range []string{"byte", "short", "int", "long"} {
+		if _, ok := got.Int(key); !ok {
+			t.Errorf("Int didn't read %s", key)
+		}
+	}
+	if _, ok := got.Int("string"); ok {
+		t.Error("Int read a string")
+	}
+	if got.String("string") != "héllo" || !reflect.DeepEqual(got.IntArray("ints"), []int32{1, -64, 3}) {
+		t.Error("String or IntArray didn't read their values")
+	}
+}
+
+func TestReadMalformed(t *testing.T) {
+	valid := (&builder{}).tag(tagCompound, "").tag(tagString, "name").str("level").tag(tagInt, "n").num(int32(1)).end().Bytes()
+
+	cases := []struct {
+		name string
+		data []byte
+		want error
+	}{
+		{"empty", []byte{}, io.ErrUnexpectedEOF},
+		{"root isn't a compound", (&builder{}).tag(tagInt, "").num(int32(1)).Bytes(), ErrMalformed},
+		{"truncated in a name", valid[:5], io.ErrUnexpectedEOF},
+		{"truncated in a value", valid[:len(valid)-3], io.ErrUnexpectedEOF},
+		{"missing end", valid[:len(valid)-1], io.ErrUnexpectedEOF},
+		{"negative length", (&builder{}).tag(tagCompound, "").tag(tagIntArray, "a").num(int32(-1)).end().Bytes(), ErrMalformed},
+		{"length too long", (&builder{}).tag(tagCompound, "").tag(tagByteArray, "a").num(int32(maxArrayLength + 1)).end().Bytes(), ErrMalformed},
+		{"length past the end", (&builder{}).tag(tagCompound, "").tag(tagLongArray, "a").num(int32(1000)).num(int64(1)).Bytes(), io.ErrUnexpectedEOF},
+		{"unknown tag", (&builder{}).tag(tagCompound, "").tag(42, "a").end().Bytes(), ErrMalformed},
+	}
+
+	deep := (&builder{}).tag(tagCompound, "")
+	for i := 0; i <= maxDepth; i++ {
+		deep.tag(tagCompound, "")
+	}
+	cases = append(cases, struct {
+		name string
+		data []byte
+		want error
+	}{"nested too deeply", deep.Bytes(), ErrMalformed})
+
+	for _, c := range cases {
+		_, err := Read(bytes.NewReader(c.data))
+		if !errors.Is(err, c.want) {
+			t.Errorf("%s: got error %v, want %v", c.name, err, c.want)
+		}
+	}
+}
@@ -0,0 +1,313 @@
+package utils
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// TarGzDir writes the contents of a directory to a gzipped tarball, with paths relative to the directory
+func TarGzDir(dir string, destination string) error {
+	out, err := os.Create(destination)
+	if err != nil {
+		return err
+	}
+
+	err = writeTarGz(dir, out)
+	closeErr := out.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(destination)
+	}
+	return err
+}
+
+func writeTarGz(dir string, out io.Writer) error {
+	gz := gzip.NewWriter(out)
+	tw := tar.NewWriter(gz)
+
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil || rel == "." {
+			return err
+		}
+		if !info.Mode().IsRegular() && !info.IsDir() {
+			// sockets, symlinks and the like have no business in a world
+			return nil
+		}
+
+		header, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return err
+		}
+		header.Name = filepath.ToSlash(rel)
+		if info.IsDir() {
+			header.Name += "/"
+		}
+		err = tw.WriteHeader(header)
+		if err != nil || info.IsDir() {
+			return err
+		}
+
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		_, err = io.CopyN(tw, file, header.Size)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	err = tw.Close()
+	if err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+// ZipDir writes the contents of a directory to a zip file, with paths relative to the directory. anything at one of
+// the excluded relative paths (and everything under it) is left out
+func ZipDir(dir string, destination string, exclude []string) error {
+	out, err := os.Create(destination)
+	if err != nil {
+		return err
+	}
+
+	err = writeZip(dir, out, exclude)
+	closeErr := out.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(destination)
+	}
+	return err
+}
+
+func writeZip(dir string, out io.Writer, exclude []string) error {
+	zw := zip.NewWriter(out)
+
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil || rel == "." {
+			return err
+		}
+		if contains(exclude, filepath.ToSlash(rel)) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !info.Mode().IsRegular() && !info.IsDir() {
+			return nil
+		}
+
+		header, err := zip.FileInfoHeader(info)
+		if err != nil {
+			return err
+		}
+		header.Name = filepath.ToSlash(rel)
+		if info.IsDir() {
+			header.Name += "/"
+			_, err = zw.CreateHeader(header)
+			return err
+		}
+		header.Method = zip.Deflate
+		writer, err := zw.CreateHeader(header)
+		if err != nil {
+			return err
+		}
+
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		_, err = io.Copy(writer, file)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+// SplitFile cuts a file into numbered parts of at most partSize bytes (<file>.001, <file>.002, ...) that can be
+// joined back together in order, and returns their paths
+func SplitFile(path string, partSize int64) ([]string, error) {
+	in, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer in.Close()
+
+	parts := make([]string, 0)
+	for {
+		part := fmt.Sprintf("%s.%03d", path, len(parts)+1)
+		out, err := os.Create(part)
+		if err != nil {
+			return parts, err
+		}
+		written, err := io.CopyN(out, in, partSize)
+		out.Close()
+		if written == 0 {
+			os.Remove(part)
+			if err == io.EOF {
+				return parts, nil
+			}
+			return parts, err
+		}
+		parts = append(parts, part)
+		if err == io.EOF {
+			return parts, nil
+		}
+		if err != nil {
+			return parts, err
+		}
+	}
+}
+
+// ExtractTarGz unpacks a gzipped tarball into a directory, refusing any entry that would land outside of it
+func ExtractTarGz(source string, dir string) error {
+	in, err := os.Open(source)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	gz, err := gzip.NewReader(in)
+	if err != nil {
+		return err
+	}
+	defer gz.Close()
+
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		target, err := SafeJoin(dir, header.Name)
+		if err != nil {
+			return err
+		}
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			err = os.MkdirAll(target, 0755)
+		case tar.TypeReg:
+			err = extractFile(tr, target, os.FileMode(header.Mode).Perm())
+		default:
+			// links and devices aren't something a world archive should contain
+			continue
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// ErrArchiveTooLarge is returned when an archive unpacks to more than it's allowed to
+var ErrArchiveTooLarge = errors.New("archive is too large")
+
+// ExtractZip unpacks a zip file into a directory, refusing any entry that would land outside of it and giving up
+// once more than maxSize bytes have been unpacked
+func ExtractZip(source string, dir string, maxSize int64) error {
+	archive, err := zip.OpenReader(source)
+	if err != nil {
+		return err
+	}
+	defer archive.Close()
+
+	// the sizes in the archive can lie, so they're only a first check
+	var declared uint64
+	for _, file := range archive.File {
+		declared += file.UncompressedSize64
+	}
+	if declared > uint64(maxSize) {
+		return ErrArchiveTooLarge
+	}
+
+	remaining := maxSize
+	for _, file := range archive.File {
+		target, err := SafeJoin(dir, file.Name)
+		if err != nil {
+			return err
+		}
+
+		if file.FileInfo().IsDir() {
+			err = os.MkdirAll(target, 0755)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		if !file.Mode().IsRegular() {
+			continue
+		}
+
+		reader, err := file.Open()
+		if err != nil {
+			return err
+		}
+		limited := &io.LimitedReader{R: reader, N: remaining + 1}
+		err = extractFile(limited, target, 0644)
+		reader.Close()
+		if err != nil {
+			return err
+		}
+		remaining = limited.N - 1
+		if remaining < 0 {
+			return ErrArchiveTooLarge
+		}
+	}
+	return nil
+}
+
+func extractFile(r io.Reader, target string, mode os.FileMode) error {
+	err := os.MkdirAll(filepath.Dir(target), 0755)
+	if err != nil {
+		return err
+	}
+	out, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, mode|0600)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(out, r)
+	closeErr := out.Close()
+	if err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// SafeJoin joins an archive entry's name onto a directory, failing if the result would escape the directory
+func SafeJoin(dir string, name string) (string, error) {
+	target := filepath.Join(dir, filepath.FromSlash(name))
+	rel, err := filepath.Rel(dir, target)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) || filepath.IsAbs(name) {
+		return "", fmt.Errorf("archive entry \"%s\" points outside of the destination", name)
+	}
+	return target, nil
+}
@@ -0,0 +1,181 @@
+package utils
+
+import (
+	"archive/zip"
+	"bytes"
+	"hash/crc32"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSafeJoin(t *testing.T) {
+	dir := filepath.Join(os.TempDir(), "extract")
+	cases := []struct {
+		name string
+		ok   bool
+	}{
+		{"level.dat", true},
+		{"world/region/r.0.0.mca", true},
+		{"world/../level.dat", true},
+		{"world/", true},
+		{"../evil", false},
+		{"world/../../evil", false},
+		{"..", false},
+		{"/etc/passwd", false},
+		{"/world/level.dat", false},
+	}
+	for _, c := range cases {
+		target, err := SafeJoin(dir, c.name)
+		if c.ok && err != nil {
+			t.Errorf("%q: unexpected error %v", c.name, err)
+		}
+		if !c.ok && err == nil {
+			t.Errorf("%q: expected an error, got %q", c.name, target)
+		}
+		if c.ok && err == nil && !strings.HasPrefix(target, dir) {
+			t.Errorf("%q: %q is outside of %q", c.name, target, dir)
+		}
+	}
+}
+
+// zipEntry is a file to put in a test zip. a declared size other than -1 is written in place of the real one
+type zipEntry struct {
+	name     string
+	contents string
+	declared int64
+}
+
+func writeTestZip(t *testing.T, entries []zipEntry) string {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, entry := range entries {
+		data := []byte(entry.contents)
+		if entry.declared < 0 {
+			w, err := zw.Create(entry.name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			w.Write(data)
+			continue
+		}
+		w, err := zw.CreateRaw(&zip.FileHeader{
+			Name:               entry.name,
+			Method:             zip.Store,
+			CRC32:              crc32.ChecksumIEEE(data),
+			CompressedSize64:   uint64(len(data)),
+			UncompressedSize64: uint64(entry.declared),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write(data)
+	}
+	err := zw.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(t.TempDir(), "test.zip")
+	err = ioutil.WriteFile(path, buf.Bytes(), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestExtractZip(t *testing.T) {
+	big := strings.Repeat("x", 100)
+	cases := []struct {
+		name    string
+		entries []zipEntry
+		maxSize int64
+		ok      bool
+	}{
+		{
+			name:    "plain world",
+			entries: []zipEntry{{"world/level.dat", "hello", -1}, {"world/region/r.0.0.mca", "chunks", -1}},
+			maxSize: 1000,
+			ok:      true,
+		},
+		{
+			name:    "parent directory",
+			entries: []zipEntry{{"world/level.dat", "hello", -1}, {"../evil", "gotcha", -1}},
+			maxSize: 1000,
+		},
+		{
+			name:    "nested parent directory",
+			entries: []zipEntry{{"world/../../evil", "gotcha", -1}},
+			maxSize: 1000,
+		},
+		{
+			name:    "absolute path",
+			entries: []zipEntry{{"/tmp/evil", "gotcha", -1}},
+			maxSize: 1000,
+		},
+		{
+			name:    "oversized entry",
+			entries: []zipEntry{{"world/level.dat", big, -1}},
+			maxSize: 50,
+		},
+		{
+			name:    "oversized in all",
+			entries: []zipEntry{{"a", big, -1}, {"b", big, -1}, {"c", big, -1}},
+			maxSize: 250,
+		},
+		{
+			name:    "declared smaller than it is",
+			entries: []zipEntry{{"world/level.dat", big, 10}},
+			maxSize: 50,
+		},
+		{
+			name:    "declared sizes add up small but the files don't",
+			entries: []zipEntry{{"a", big, 1}, {"b", big, 1}},
+			maxSize: 150,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			source := writeTestZip(t, c.entries)
+			root := t.TempDir()
+			dir := filepath.Join(root, "out")
+			err := os.Mkdir(dir, 0755)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = ExtractZip(source, dir, c.maxSize)
+			if c.ok && err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if !c.ok && err == nil {
+				t.Fatal("expected an error")
+			}
+
+			if _, err := os.Stat(filepath.Join(root, "evil")); err == nil {
+				t.Error("a file escaped the destination")
+			}
+			written, err := dirSize(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if written > c.maxSize {
+				t.Errorf("wrote %d bytes, more than the %d allowed", written, c.maxSize)
+			}
+		})
+	}
+}
+
+func dirSize(dir string) (int64, error) {
+	var size int64
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err == nil && info.Mode().IsRegular() {
+			size += info.Size()
+		}
+		return err
+	})
+	return size, err
+}
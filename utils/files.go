@@ -0,0 +1,49 @@
+package utils
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// CopyDir copies the regular files and directories under one directory into a new one, keeping their permissions
+func CopyDir(source string, destination string) error {
+	return filepath.Walk(source, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(source, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(destination, rel)
+
+		if info.IsDir() {
+			return os.MkdirAll(target, info.Mode().Perm())
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		return CopyFile(path, target, info.Mode().Perm())
+	})
+}
+
+// CopyFile copies one file to a new one with the given permissions. it won't overwrite a file that's already there
+func CopyFile(source string, destination string, mode os.FileMode) error {
+	in, err := os.Open(source)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(destination, os.O_CREATE|os.O_WRONLY|os.O_EXCL, mode)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(out, in)
+	closeErr := out.Close()
+	if err == nil {
+		err = closeErr
+	}
+	return err
+}
@@ -5,6 +5,7 @@ import (
 	"io/ioutil"
 	"regexp"
 	"strings"
+	"time"
 )
 
 // Check panics if error is not nil
@@ -23,6 +24,20 @@ func contains(col []string, s string) bool {
 	return false
 }
 
+// allowsFlag checks a flag against the allowed flags, where an allowed flag ending in ":" is a prefix for a family of
+// flags
+func allowsFlag(argFlags []string, flag string) bool {
+	for _, allowed := range argFlags {
+		if allowed == flag {
+			return true
+		}
+		if strings.HasSuffix(allowed, ":") && strings.HasPrefix(flag, allowed) && len(flag) > len(allowed) {
+			return true
+		}
+	}
+	return false
+}
+
 // MalformedParseError is an error indicating a general failure to parse a string
 type MalformedParseError struct{}
 
@@ -38,7 +53,7 @@ func (e *InvalidFlagError) Error() string {
 }
 
 // ParseArgString parses a string into a map
-// e.g. -foo=val is parsed into m["foo"] = "val"
+// e.g. -foo=val is parsed into m["foo"] = "val", and -prop:bar=val into m["prop:bar"] = "val" if "prop:" is allowed
 func ParseArgString(argString string, argFlags []string, allowUnnamed bool) (map[string]string, error) {
 	args := make(map[string]string, len(argFlags))
 
@@ -71,7 +86,7 @@ func ParseArgString(argString string, argFlags []string, allowUnnamed bool) (map
 			}
 
 			flag := argString[flagByteStart:flagByteEnd]
-			if !contains(argFlags, flag) {
+			if !allowsFlag(argFlags, flag) {
 				return nil, &InvalidFlagError{Found: flag}
 			}
 			idx = innerIdx
@@ -125,6 +140,10 @@ func ReadLastLines(bytes []byte, l int, o int) string {
 	lines := strings.Split(string(bytes), "\n")
 	firstLineIndex := len(lines) - l - o
 	lastLineIndex := len(lines) - o
+	if lastLineIndex < 0 {
+		// the offset is past the start of the file, so there's nothing left to show
+		lastLineIndex = 0
+	}
 	if firstLineIndex < 0 {
 		firstLineIndex = 0
 	}
@@ -144,6 +163,33 @@ func ReplaceNamedValueInTextFile(filename string, key string, value string) erro
 	return ioutil.WriteFile(filename, edited, 0666)
 }
 
+// SetNamedValueInTextFile sets a value for a key in a simple key=value file, appending the key if it's not already
+// present, and writes it back to disk
+func SetNamedValueInTextFile(filename string, key string, value string) error {
+	contents, err := ioutil.ReadFile(filename)
+	if err != nil {
+		fmt.Println("ERR", err)
+		return err
+	}
+	re := regexp.MustCompile(`(^|\n)` + regexp.QuoteMeta(key) + `=.*`)
+	if re.Match(contents) {
+		contents = re.ReplaceAllFunc(contents, func(match []byte) []byte {
+			prefix := ""
+			if len(match) > 0 && match[0] == '\n' {
+				prefix = "\n"
+			}
+			return []byte(prefix + key + "=" + value)
+		})
+	} else {
+		if len(contents) > 0 && contents[len(contents)-1] != '\n' {
+			contents = append(contents, '\n')
+		}
+		contents = append(contents, []byte(key+"="+value+"\n")...)
+	}
+
+	return ioutil.WriteFile(filename, contents, 0666)
+}
+
 // GetNamedValueInTextFile gets the value for a key in a simple key=falue file
 func GetNamedValueInTextFile(filename string, key string) (string, error) {
 	contents, err := ioutil.ReadFile(filename)
@@ -158,3 +204,17 @@ func GetNamedValueInTextFile(filename string, key string) (string, error) {
 	}
 	return matches[1], nil
 }
+
+// FormatDuration renders a duration as a short human readable string, i.e. "1h12m" or "3d4h"
+func FormatDuration(d time.Duration) string {
+	if d < time.Minute {
+		return fmt.Sprintf("%ds", int(d.Seconds()))
+	}
+	if d < time.Hour {
+		return fmt.Sprintf("%dm", int(d.Minutes()))
+	}
+	if d < 24*time.Hour {
+		return fmt.Sprintf("%dh%dm", int(d.Hours()), int(d.Minutes())%60)
+	}
+	return fmt.Sprintf("%dd%dh", int(d.Hours())/24, int(d.Hours())%24)
+}
@@ -0,0 +1,23 @@
+package utils
+
+import "testing"
+
+func TestReadLastLines(t *testing.T) {
+	log := []byte("one\ntwo\nthree\nfour")
+	cases := []struct {
+		l, o int
+		want string
+	}{
+		{2, 0, "three\nfour"},
+		{2, 1, "two\nthree"},
+		{10, 0, "one\ntwo\nthree\nfour"},
+		{10, 2, "one\ntwo"},
+		{2, 4, ""},
+		{2, 100, ""},
+	}
+	for _, c := range cases {
+		if got := ReadLastLines(log, c.l, c.o); got != c.want {
+			t.Errorf("ReadLastLines(l=%d, o=%d) = %q, want %q", c.l, c.o, got, c.want)
+		}
+	}
+}